@@ -142,12 +142,12 @@ class GameService {
         // Ball moves
         game.ball.x += game.ball.vx;
         game.ball.y += game.ball.vy;
-        // Top and bottom wall
-        if (
-          game.ball.y - PONG.ball.diameter / 2 <= 0 ||
-          game.ball.y + PONG.ball.diameter / 2 >= PONG.map.ySize
-        ) {
-          game.ball.vy *= -1;
+        // Top and bottom wall: send the ball away from the wall, flipping
+        // vy would keep a slow ball stuck in it.
+        if (game.ball.y - PONG.ball.diameter / 2 <= 0) {
+          game.ball.vy = Math.abs(game.ball.vy);
+        } else if (game.ball.y + PONG.ball.diameter / 2 >= PONG.map.ySize) {
+          game.ball.vy = -Math.abs(game.ball.vy);
         } else {
           for (let i = 0; i < game.players.length; i++) {
             const paddle = game.players[i].paddle;
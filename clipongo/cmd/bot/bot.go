@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"clipongo/pkg/api"
+	"clipongo/pkg/pong/engine"
+	"clipongo/pkg/realtime"
+)
+
+// moveBurst is how long the paddle of a slow bot moves in a row. Moving
+// every other tick would send a paddle_move per frame, which latency
+// scrambles anyway.
+const moveBurst = 200 * time.Millisecond
+
+// findGame returns the first game of the user that lists them as a player
+// and is not over, or nil if there is none.
+func findGame(ctx context.Context, client *api.Client) (*api.GameState, error) {
+	games, err := client.ListGamesDetailed(ctx, api.DefaultListWorkers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list games: %w", err)
+	}
+	for _, g := range games {
+		if g.Err != nil {
+			// Most likely ended since it was listed.
+			continue
+		}
+		if g.State.PlayerIndex(client.GetUsername()) >= 0 && !over(g.State) {
+			return g.State, nil
+		}
+	}
+	return nil, nil
+}
+
+// waitGame fetches game id, or when id is empty waits for a game to play,
+// looking every interval.
+func waitGame(ctx context.Context, client *api.Client, id string, interval time.Duration) (*api.GameState, error) {
+	if id != "" {
+		state, err := client.GetGameState(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch game %s: %w", id, err)
+		}
+		if state.PlayerIndex(client.GetUsername()) < 0 {
+			return nil, fmt.Errorf("%s is not a player of game %s", client.GetUsername(), id)
+		}
+		return state, nil
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		state, err := findGame(ctx, client)
+		if err != nil {
+			log.Printf("Looking for a game: %v", err)
+		} else if state != nil {
+			return state, nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// playGame joins game and moves the paddle of the user with an AI of the
+// given skill until a team wins. It returns the final state, or nil when
+// the game ended without the bot seeing the last frame.
+func playGame(ctx context.Context, client *api.Client, game *api.GameState, skill engine.Skill, seed uint64) (*api.GameState, error) {
+	slot := game.PlayerIndex(client.GetUsername())
+	if slot < 0 {
+		return nil, fmt.Errorf("%s is not a player of game %s", client.GetUsername(), game.ID)
+	}
+	ai := engine.NewAI(slot, skill, seed)
+	ai.SetBurst(int(moveBurst / engine.Tick))
+	session := realtime.NewSession(client, game.ID)
+	final := make(chan *api.GameState, 1)
+	// The AI counts on one frame per tick, which the server sends.
+	session.OnGameState(func(state *api.GameState) {
+		if over(state) {
+			select {
+			case final <- state:
+			default:
+			}
+			return
+		}
+		if state.Pause {
+			return
+		}
+		for _, move := range ai.Next(state) {
+			move.Paddle = slot
+			if err := session.SendPaddleMove(move); err != nil {
+				log.Printf("Failed to send paddle move: %v", err)
+			}
+		}
+	})
+	if err := session.Connect(ctx); err != nil {
+		return nil, fmt.Errorf("failed to join game %s: %w", game.ID, err)
+	}
+	defer session.Close()
+
+	select {
+	case state := <-final:
+		return state, nil
+	case <-session.Done():
+		// The backend drops the game once it is won, which may close the
+		// socket before the last frame is read.
+		if _, err := client.GetGameState(ctx, game.ID); errors.Is(err, api.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("lost game %s: %w", game.ID, session.Err())
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// over tells if a team won state.
+func over(state *api.GameState) bool {
+	for _, p := range state.Players {
+		if p.Player.Won {
+			return true
+		}
+	}
+	return false
+}
+
+// result describes how the game ended for username.
+func result(state *api.GameState, username string) string {
+	if state == nil || len(state.Players) < 2 {
+		return "the game ended"
+	}
+	left, right := state.Players[0].Player.Score, state.Players[1].Player.Score
+	team := api.Team(state.PlayerIndex(username))
+	won := state.Players[team].Player.Won
+	if team == 1 {
+		left, right = right, left
+	}
+	if won {
+		return fmt.Sprintf("won %d - %d", left, right)
+	}
+	return fmt.Sprintf("lost %d - %d", left, right)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"clipongo/pkg/api"
+	"clipongo/pkg/apitest"
+	"clipongo/pkg/pong/engine"
+	"clipongo/pkg/realtime"
+)
+
+const waitTimeout = 5 * time.Second
+
+func login(t *testing.T, srv *apitest.Server, username string) *api.Client {
+	t.Helper()
+	client, err := api.NewClient(srv.URL, srv.Login(username), username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestFindGame(t *testing.T) {
+	srv := apitest.NewServer()
+	t.Cleanup(srv.Close)
+	client := login(t, srv, "bot")
+
+	if game, err := findGame(context.Background(), client); err != nil || game != nil {
+		t.Fatalf("findGame() = %v, %v before any game, want nil", game, err)
+	}
+	srv.CreateGame("alice", "carol")
+	want := srv.CreateGame("alice", "bot")
+	game, err := findGame(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game == nil || game.ID != want.ID {
+		t.Errorf("findGame() = %+v, want game %s", game, want.ID)
+	}
+}
+
+func TestPlayGameMovesToTheBall(t *testing.T) {
+	tests := []struct {
+		name  string
+		skill engine.Skill
+		// maxMoves bounds the moves sent over 30 frames of a ball the
+		// paddle, stuck at the top, never reaches.
+		maxMoves int
+	}{
+		{"full speed", engine.Skill{Speed: 1}, 1},
+		// One stop and start per frame without bursts.
+		{"half speed", engine.Skill{Speed: 0.5}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := apitest.NewServer()
+			t.Cleanup(srv.Close)
+			client := login(t, srv, "bot")
+			game := srv.CreateGame("alice", "bot")
+
+			type outcome struct {
+				state *api.GameState
+				err   error
+			}
+			done := make(chan outcome, 1)
+			go func() {
+				state, err := playGame(context.Background(), client, &game, tt.skill, 1)
+				done <- outcome{state, err}
+			}()
+			if err := srv.WaitJoined(game.ID, "bot", waitTimeout); err != nil {
+				t.Fatal(err)
+			}
+
+			// The ball heads to the bottom of the right paddle, which is at
+			// the top.
+			state, _ := srv.Game(game.ID)
+			state.Pause = false
+			state.Players[1].Paddle.Y = 0
+			state.Ball = api.Ball{X: 500, Y: 250, Vx: 5, Vy: 1}
+			for range 30 {
+				if err := srv.SendState(game.ID, state); err != nil {
+					t.Fatal(err)
+				}
+			}
+			deadline := time.Now().Add(waitTimeout)
+			for len(srv.Moves(game.ID)) == 0 {
+				if time.Now().After(deadline) {
+					t.Fatal("no paddle move received")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			want := realtime.PaddleMove{Paddle: 1, Direction: realtime.Down, Moving: true}
+			if got := srv.Moves(game.ID)[0]; got != want {
+				t.Errorf("first move = %+v, want %+v", got, want)
+			}
+
+			state.Players[0].Player.Score = engine.WinScore
+			state.Players[0].Player.Won = true
+			if err := srv.SendState(game.ID, state); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case o := <-done:
+				if o.err != nil {
+					t.Fatalf("playGame: %v", o.err)
+				}
+				if got := result(o.state, "bot"); got != "lost 0 - 3" {
+					t.Errorf("result = %q, want %q", got, "lost 0 - 3")
+				}
+			case <-time.After(waitTimeout):
+				t.Fatal("playGame did not return after the game was won")
+			}
+			if moves := srv.Moves(game.ID); len(moves) > tt.maxMoves {
+				t.Errorf("%d moves sent, want at most %d: %+v", len(moves), tt.maxMoves, moves)
+			}
+		})
+	}
+}
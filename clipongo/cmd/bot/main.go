@@ -0,0 +1,115 @@
+// Command bot plays online games against people without a terminal: it
+// logs in, waits for games listing it as a player and moves its paddle to
+// where it predicts the ball will cross it.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"clipongo/pkg/api"
+	"clipongo/pkg/config"
+	"clipongo/pkg/pong/engine"
+)
+
+func main() {
+	var flags config.Config
+	configPath := flag.String("config", "", "path to the config file (default $CLIPONGO_CONFIG or <config dir>/clipongo/config.json)")
+	flag.StringVar(&flags.Server, "server", "", "API server URL (default $CLIPONGO_SERVER, then the config file, then "+config.DefaultServer+")")
+	flag.StringVar(&flags.CAFile, "ca-file", "", "PEM file of extra certificate authorities to trust (default $CLIPONGO_CA_FILE)")
+	flag.BoolVar(&flags.TrustOnFirstUse, "tofu", false, "pin the server certificate on first use instead of verifying its chain")
+	flag.StringVar(&flags.KnownHosts, "known-hosts", "", "known hosts file used to pin certificates (implies -tofu)")
+	flag.BoolVar(&flags.Insecure, "insecure", false, "DANGEROUS: skip TLS certificate verification")
+	username := flag.String("user", "bot", "username to log in and play as")
+	gameID := flag.String("game", "", "play this game only, instead of waiting for games")
+	once := flag.Bool("once", false, "exit after one game")
+	pollInterval := flag.Duration("poll-interval", 2*time.Second, "how often to look for new games")
+	difficulty := flag.String("difficulty", "medium", "skill preset: easy, medium or hard")
+	reaction := flag.Duration("reaction-delay", 0, "override the time taken to notice the ball changed direction")
+	speed := flag.Float64("speed", 0, "override the share of the ticks the paddle moves on, up to 1")
+	aimError := flag.Float64("error", 0, "override how far off the bot may aim, in game units (500 is the field height)")
+	flag.Parse()
+
+	skill, err := engine.LookupDifficulty(*difficulty)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "reaction-delay":
+			skill.ReactionDelay = *reaction
+		case "speed":
+			skill.Speed = *speed
+		case "error":
+			skill.Error = *aimError
+		}
+	})
+	if skill.Speed <= 0 || skill.Speed > 1 || skill.Error < 0 || skill.ReactionDelay < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -speed must be in (0, 1], -error and -reaction-delay not negative")
+		os.Exit(2)
+	}
+
+	cfg, err := config.Resolve(*configPath, flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client, err := api.NewClient(cfg.Server, "", *username, cfg.ClientOptions(func(host, fingerprint string) {
+		log.Printf("Trusting %s on first use, certificate fingerprint: %s", host, fingerprint)
+	})...)
+	if err != nil {
+		log.Fatalf("Failed to create client: %v", err)
+	}
+	token, err := client.Authenticate(ctx, *username)
+	if err != nil {
+		log.Fatalf("Failed to log in as %s: %v", *username, err)
+	}
+	client.SetToken(token)
+	log.Printf("Logged in as %s on %s (%s: reaction %s, speed %.2f, error %.0f)",
+		*username, client.BaseURL(), *difficulty, skill.ReactionDelay, skill.Speed, skill.Error)
+
+	for {
+		game, err := waitGame(ctx, client, *gameID, *pollInterval)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Fatalf("Failed to find a game: %v", err)
+		}
+		log.Printf("Playing game %s", game.ID)
+		final, err := playGame(ctx, client, game, skill, uint64(time.Now().UnixNano()))
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil:
+			log.Printf("Game %s failed: %v", game.ID, err)
+		default:
+			log.Printf("Game %s over: %s", game.ID, result(final, *username))
+		}
+		if *once || *gameID != "" {
+			if err != nil {
+				os.Exit(1)
+			}
+			return
+		}
+		if err != nil {
+			// Do not hammer a game that keeps failing.
+			select {
+			case <-time.After(*pollInterval):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
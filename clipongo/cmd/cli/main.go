@@ -0,0 +1,700 @@
+package main
+
+import (
+	"bufio"
+	"clipongo/pkg/api"
+	"clipongo/pkg/config"
+	"clipongo/pkg/pong"
+	"clipongo/pkg/pong/engine"
+	"clipongo/pkg/session"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func displayWelcome() {
+	fmt.Println(`
+	 ██████╗██╗     ██╗██████╗  ██████╗ ███╗   ██╗ ██████╗ ██████╗
+	██╔════╝██║     ██║██╔══██╗██╔═══██╗████╗  ██║██╔════╝ ██╔═══██╗
+	██║     ██║     ██║██████╔╝██║   ██║██╔██╗ ██║██║  ███╗██║   ██║
+	██║     ██║     ██║██╔═══╝ ██║   ██║██║╚██╗██║██║   ██║██║   ██║
+	╚██████╗╚██████╗██║██║     ╚██████╔╝██║ ╚████║╚██████╔╝╚██████╔╝
+	 ╚═════╝ ╚═════╝╚═╝╚═╝      ╚═════╝ ╚═╝  ╚═══╝ ╚═════╝  ╚═════╝`)
+}
+
+func main() {
+	var flags config.Config
+	configPath := flag.String("config", "", "path to the config file (default $CLIPONGO_CONFIG or <config dir>/clipongo/config.json)")
+	flag.StringVar(&flags.Server, "server", "", "API server URL (default $CLIPONGO_SERVER, then the config file, then "+config.DefaultServer+")")
+	flag.StringVar(&flags.CAFile, "ca-file", "", "PEM file of extra certificate authorities to trust (default $CLIPONGO_CA_FILE)")
+	flag.BoolVar(&flags.TrustOnFirstUse, "tofu", false, "pin the server certificate on first use instead of verifying its chain")
+	flag.StringVar(&flags.KnownHosts, "known-hosts", "", "known hosts file used to pin certificates (implies -tofu)")
+	flag.BoolVar(&flags.Insecure, "insecure", false, "DANGEROUS: skip TLS certificate verification")
+	flag.BoolVar(&flags.HUD, "hud", false, "show the network quality during games (toggle with H)")
+	flag.BoolVar(&flags.RawBall, "raw-ball", false, "draw the ball as received instead of predicting it between frames (toggle with R)")
+	flag.Var(&flags.PollInterval, "poll-interval", "how often a spectated game is fetched when its WebSocket is refused (default 100ms)")
+	flag.BoolVar(&flags.Mouse, "mouse", false, "move the paddle with the mouse pointer")
+	flag.StringVar(&flags.Keymap, "keymap", "", "key bindings file (default <config dir>/clipongo/keys.json)")
+	flag.Var(&flags.ReconnectTimeout, "reconnect-timeout", "how long to keep reconnecting a lost game, e.g. 45s (default 30s)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [command]\n\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "Commands:")
+		fmt.Fprintln(flag.CommandLine.Output(), "  calibrate\tmeasure the key auto-repeat of the terminal and save it")
+		fmt.Fprintln(flag.CommandLine.Output(), "  keys\t\tprint the key bindings")
+		fmt.Fprintln(flag.CommandLine.Output(), "\nFlags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	cfg, err := config.Resolve(*configPath, flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	keymap, err := pong.LoadKeymap(cfg.Keymap)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch cmd := flag.Arg(0); cmd {
+	case "":
+	case "keys":
+		fmt.Printf("Key bindings (change them in %s):\n\n", cfg.Keymap)
+		keymap.Print(os.Stdout)
+		return
+	case "calibrate":
+		if err := calibrate(*configPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n", cmd)
+		flag.Usage()
+		os.Exit(2)
+	}
+	if cfg.Insecure {
+		fmt.Fprintln(os.Stderr, "\033[1;31mWARNING: TLS certificate verification is DISABLED.\033[0m")
+		fmt.Fprintln(os.Stderr, "\033[1;31mYour session token can be read by anyone able to intercept the connection.\033[0m")
+		fmt.Fprint(os.Stderr, "Press Enter to continue anyway...")
+		bufio.NewReader(os.Stdin).ReadString('\n')
+	}
+
+	clearScreen()
+	displayWelcome()
+
+	f, err := os.OpenFile("clipongo.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		log.Fatalf("error opening file: %v", err)
+	}
+	defer f.Close()
+
+	log.SetOutput(f)
+
+	sessionsPath, err := session.DefaultPath()
+	if err != nil {
+		log.Fatalf("error locating sessions: %v", err)
+	}
+	store := session.NewFileStore(sessionsPath)
+
+	if client, ok := resumeSession(cfg, store); ok {
+		play(client, store, cfg, keymap)
+	}
+	for {
+		action := getAction()
+
+		switch action {
+		case "1":
+			client, ok := handleLogin(cfg, store)
+			if ok {
+				play(client, store, cfg, keymap)
+			}
+		case "2":
+			exit()
+		default:
+			fmt.Println("\n Invalid choice. Please try again.")
+		}
+	}
+}
+
+func getAction() string {
+	reader := bufio.NewReader(os.Stdin)
+
+	for {
+		fmt.Println("\n What would you like to do?")
+		fmt.Println("1. Login")
+		fmt.Println("2. Exit")
+		fmt.Print("\n Enter your choice (1-2): ")
+
+		choice, _ := reader.ReadString('\n')
+		choice = strings.TrimSpace(choice)
+
+		if choice == "1" || choice == "2" {
+			return choice
+		}
+		fmt.Println("\n Please enter a number between 1 and 2")
+	}
+}
+
+func getGameMode() string {
+	reader := bufio.NewReader(os.Stdin)
+
+	for {
+		fmt.Println("\n Select Game Mode")
+		fmt.Println("1. Multiplayer Pong (Host)")
+		fmt.Println("2. Join Multiplayer Game")
+		fmt.Println("3. Play vs Computer")
+		fmt.Println("4. Hot-seat (two players, one keyboard)")
+		fmt.Println("5. Spectate a Game")
+		fmt.Println("6. Logout")
+		fmt.Println("7. Exit")
+		fmt.Print("\n Enter your choice (1-7): ")
+
+		choice, _ := reader.ReadString('\n')
+		choice = strings.TrimSpace(choice)
+
+		if choice >= "1" && choice <= "7" {
+			return choice
+		}
+		fmt.Println("\n Please enter a number between 1 and 7")
+	}
+}
+
+// exit says goodbye and ends the program, keeping any saved session.
+func exit() {
+	clearScreen()
+	fmt.Println("\n Bye-Bye and thank you for playing")
+	fmt.Println("\n The codebase was provided by Moutillon Tech & Associates")
+	os.Exit(0)
+}
+
+// play runs the game mode menu until the user logs out, then forgets their
+// session. Exiting from the menu keeps it for the next run.
+func play(client *api.Client, store session.TokenStore, cfg *config.Config, keymap *pong.Keymap) {
+	opts := pong.Options{
+		ReconnectTimeout: time.Duration(cfg.ReconnectTimeout),
+		HUD:              cfg.HUD,
+		RawBall:          cfg.RawBall,
+		Mouse:            cfg.Mouse,
+		PollInterval:     time.Duration(cfg.PollInterval),
+		KeyRepeat: pong.KeyRepeat{
+			Delay:    time.Duration(cfg.KeyRepeatDelay),
+			Interval: time.Duration(cfg.KeyRepeatInterval),
+		},
+		Keymap: keymap,
+	}
+	handleGameMode(client, opts)
+	if err := store.Delete(client.BaseURL(), client.GetUsername()); err != nil {
+		log.Printf("Failed to delete session: %v", err)
+	}
+}
+
+// newClient returns a client for the configured server which saves its
+// token in store whenever it logs in again.
+func newClient(cfg *config.Config, store session.TokenStore, token, username string) (*api.Client, error) {
+	opts := cfg.ClientOptions(func(host, fingerprint string) {
+		fmt.Printf("\n Trusting %s on first use\n Certificate fingerprint: %s\n", host, fingerprint)
+	})
+	var client *api.Client
+	opts = append(opts, api.WithTokenRefresh(func(token string) {
+		saveSession(store, client, token)
+	}))
+	client, err := api.NewClient(cfg.Server, token, username, opts...)
+	return client, err
+}
+
+func saveSession(store session.TokenStore, client *api.Client, token string) {
+	err := store.Save(session.Session{
+		Server:   client.BaseURL(),
+		Username: client.GetUsername(),
+		Token:    token,
+	})
+	if err != nil {
+		log.Printf("Failed to save session: %v", err)
+	}
+}
+
+// resumeSession logs in with the token saved by a previous run, if the
+// server still accepts it.
+func resumeSession(cfg *config.Config, store session.TokenStore) (*api.Client, bool) {
+	probe, err := newClient(cfg, store, "", "")
+	if err != nil {
+		return nil, false
+	}
+	saved, err := store.Load(probe.BaseURL())
+	if err != nil {
+		if !errors.Is(err, session.ErrNoSession) {
+			log.Printf("Failed to load session: %v", err)
+		}
+		return nil, false
+	}
+
+	client, err := newClient(cfg, store, saved.Token, saved.Username)
+	if err != nil {
+		return nil, false
+	}
+	fmt.Printf("\n Resuming the session of %s...\n", saved.Username)
+	ctx, cancel := interruptible()
+	user, err := client.CurrentUser(ctx)
+	cancel()
+	if err != nil {
+		fmt.Printf(" Could not resume the session: %s\n", describeError(err, "The user endpoint was not found: check the -server URL."))
+		if errors.Is(err, api.ErrUnauthorized) {
+			store.Delete(saved.Server, saved.Username)
+		}
+		return nil, false
+	}
+	if user.Username != saved.Username {
+		fmt.Printf(" Could not resume the session: the token belongs to %s\n", user.Username)
+		store.Delete(saved.Server, saved.Username)
+		return nil, false
+	}
+	return client, true
+}
+
+func handleLogin(cfg *config.Config, store session.TokenStore) (*api.Client, bool) {
+	clearScreen()
+	displayWelcome()
+
+	fmt.Println("\n Login to Your Account")
+	fmt.Println("-----------------------")
+
+	username, err := getCredentials()
+	if err != nil {
+		fmt.Printf("\nError: %v\n", err)
+		return nil, false
+	}
+
+	client, err := newClient(cfg, store, "", username)
+	if err != nil {
+		fmt.Printf("\n Error: %v\n", err)
+		return nil, false
+	}
+	ctx, cancel := interruptible()
+	token, err := client.Authenticate(ctx, username)
+	cancel()
+	if err != nil {
+		fmt.Printf("\n Authentication failed: %s\n", describeError(err, "The login endpoint was not found: check the -server URL."))
+		return nil, false
+	}
+	client.SetToken(token)
+	saveSession(store, client, token)
+	fmt.Println("\n Login successful!")
+	fmt.Println(sessionStatus(client))
+	return client, true
+}
+
+// handleGameMode runs the game mode menu until the user logs out. Exit ends
+// the program from there.
+func handleGameMode(client *api.Client, opts pong.Options) {
+	reader := bufio.NewReader(os.Stdin)
+
+	for {
+		clearScreen()
+		displayWelcome()
+		fmt.Println(sessionStatus(client))
+		mode := getGameMode()
+
+		switch mode {
+		case "1": // Host the pong game
+			clearScreen()
+			fmt.Println("\nHost a Multiplayer Game")
+			fmt.Println("-------------------------")
+			opponents, ok := pickPlayers(reader, client.GetUsername())
+			if !ok {
+				continue
+			}
+			fmt.Println("\nCreating game... (Ctrl+C to cancel)")
+			ctx, cancel := interruptible()
+			game, err := client.CreateGame(ctx, opponents...)
+			cancel()
+			if err != nil {
+				fmt.Printf("\nFailed to create game: %s\n", describeError(err, "Check the opponent username and try again."))
+				fmt.Println("Press Enter to continue...")
+				reader.ReadBytes('\n')
+				continue
+			}
+			fmt.Printf("\nGame created! Waiting for opponent to join...\n")
+			fmt.Printf("Spectators can watch it with the game ID %s\n", game.ID)
+			ctx, cancel = interruptible()
+			err = pong.StartGame(ctx, client, game.ID, 1, opts)
+			cancel()
+			if err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("Game %s failed: %v", game.ID, err)
+				fmt.Printf("\nThe game failed: %v\n", err)
+				fmt.Println("Press Enter to continue...")
+				reader.ReadBytes('\n')
+			}
+			continue
+		case "2": // Join the pong game
+			clearScreen()
+			displayWelcome()
+			fmt.Println("Join Multiplayer Game")
+			fmt.Println("----------------------")
+
+			ctx, cancel := interruptible()
+			games, err := client.ListGamesDetailed(ctx, api.DefaultListWorkers)
+			cancel()
+			if err != nil {
+				fmt.Printf("\nFailed to fetch games: %s\n", describeError(err, "The games endpoint was not found: check the -server URL."))
+				fmt.Println("Press Enter to continue...")
+				reader.ReadBytes('\n')
+				continue
+			}
+
+			if len(games) == 0 {
+				fmt.Println("\nNo games available to join.")
+				fmt.Println("Press Enter to continue...")
+				reader.ReadBytes('\n')
+				continue
+			}
+
+			fmt.Println("\nAvailable games to join:")
+			for i, g := range games {
+				fmt.Printf("%d. %s\n", i+1, describeGame(g, client.GetUsername()))
+			}
+			for {
+				fmt.Print("\nSelect a game number to join (or 0 to cancel): ")
+				choice, _ := reader.ReadString('\n')
+				choice = strings.TrimSpace(choice)
+
+				if choice == "0" {
+					break
+				}
+				gameIndex, err := strconv.Atoi(choice)
+				if err != nil || gameIndex < 1 || gameIndex > len(games) {
+					fmt.Println("Invalid selection. Please try again.")
+					continue
+				}
+
+				game := games[gameIndex-1]
+				if game.Err != nil {
+					fmt.Printf("Cannot join game %q: %s\n", game.ID, describeError(game.Err, "This game has ended, pick another one."))
+					continue
+				}
+				slot := game.State.PlayerIndex(client.GetUsername())
+				if slot < 0 {
+					fmt.Println("You are not a player of this game.")
+					continue
+				}
+
+				host := game.State.Players[0].Player.Username
+				fmt.Printf("\nJoining game %s hosted by %s...\n", game.ID, host)
+				ctx, cancel := interruptible()
+				err = pong.StartGame(ctx, client, game.ID, slot+1, opts)
+				cancel()
+				if err != nil && !errors.Is(err, context.Canceled) {
+					log.Printf("Game %s failed: %v", game.ID, err)
+					fmt.Printf("\nThe game failed: %v\n", err)
+					fmt.Println("Press Enter to continue...")
+					reader.ReadBytes('\n')
+				}
+				break // exit the join loop and re-draw the menu
+			}
+		case "3": // Play a local game against the AI
+			clearScreen()
+			displayWelcome()
+			fmt.Println("Play vs Computer")
+			fmt.Println("-----------------")
+			opponent, ok := pickOpponent(reader)
+			if !ok {
+				continue
+			}
+			ctx, cancel := interruptible()
+			err := pong.PlayLocal(ctx, client.GetUsername(), opponent, opts)
+			cancel()
+			if err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("Local game failed: %v", err)
+				fmt.Printf("\nThe game failed: %v\n", err)
+				fmt.Println("Press Enter to continue...")
+				reader.ReadBytes('\n')
+			}
+		case "4": // Play a local game between two people on one keyboard
+			clearScreen()
+			displayWelcome()
+			fmt.Println("Hot-seat")
+			fmt.Println("---------")
+			left, right, ok := pickHotSeatNames(reader, client.GetUsername(), opts.Keymap.HotSeat())
+			if !ok {
+				continue
+			}
+			ctx, cancel := interruptible()
+			err := pong.PlayLocal(ctx, left, pong.Opponent{Name: right, Human: true}, opts)
+			cancel()
+			if err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("Hot-seat game failed: %v", err)
+				fmt.Printf("\nThe game failed: %v\n", err)
+				fmt.Println("Press Enter to continue...")
+				reader.ReadBytes('\n')
+			}
+		case "5": // Watch a game without playing
+			clearScreen()
+			displayWelcome()
+			fmt.Println("Spectate a Game")
+			fmt.Println("----------------")
+			fmt.Print("\nEnter the game ID (empty to cancel): ")
+			id, _ := reader.ReadString('\n')
+			id = strings.TrimSpace(id)
+			if id == "" {
+				continue
+			}
+			ctx, cancel := interruptible()
+			err := pong.StartGame(ctx, client, id, pong.Spectator, opts)
+			cancel()
+			if err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("Watching game %s failed: %v", id, err)
+				fmt.Printf("\nCannot watch the game: %s\n", describeError(err, "No such game, check the ID."))
+				fmt.Println("Press Enter to continue...")
+				reader.ReadBytes('\n')
+			}
+		case "6":
+			fmt.Println("\n Logging out...")
+			clearScreen()
+			return
+		case "7":
+			exit()
+		}
+	}
+}
+
+// pickOpponent asks for the difficulty of the AI, or a practice wall.
+func pickOpponent(reader *bufio.Reader) (pong.Opponent, bool) {
+	fmt.Println()
+	for i, d := range engine.Difficulties {
+		fmt.Printf("%d. %s\n", i+1, strings.ToUpper(d.Name[:1])+d.Name[1:])
+	}
+	practice := len(engine.Difficulties) + 1
+	fmt.Printf("%d. Practice against a wall\n", practice)
+	for {
+		fmt.Printf("\nSelect a difficulty (or 0 to cancel): ")
+		choice, _ := reader.ReadString('\n')
+		n, err := strconv.Atoi(strings.TrimSpace(choice))
+		switch {
+		case err != nil || n < 0 || n > practice:
+			fmt.Println("Invalid selection. Please try again.")
+		case n == 0:
+			return pong.Opponent{}, false
+		case n == practice:
+			return pong.Opponent{Name: "wall", Wall: true}, true
+		default:
+			d := engine.Difficulties[n-1]
+			return pong.Opponent{Name: "cpu-" + d.Name, Skill: d.Skill}, true
+		}
+	}
+}
+
+// pickHotSeatNames asks for the names of the left and right players of a
+// hot-seat game, the left one defaulting to the logged in user. keys tells
+// each player which keys are theirs.
+func pickHotSeatNames(reader *bufio.Reader, me string, keys *pong.Keymap) (left, right string, ok bool) {
+	fmt.Println("\nTerminals only repeat the last key pressed: when both players hold a key,")
+	fmt.Println("the first paddle stops after a moment. Tap rather than hold to play at once.")
+	fmt.Printf("Left player (%s / %s), empty for %s: ",
+		keys.Hint(pong.ActionLeftUp), keys.Hint(pong.ActionLeftDown), me)
+	left, _ = reader.ReadString('\n')
+	left = strings.TrimSpace(left)
+	if left == "" {
+		left = me
+	}
+	for {
+		fmt.Printf("Right player (%s / %s), empty to cancel: ",
+			keys.Hint(pong.ActionRightUp), keys.Hint(pong.ActionRightDown))
+		right, _ = reader.ReadString('\n')
+		right = strings.TrimSpace(right)
+		switch right {
+		case "":
+			return "", "", false
+		case left:
+			fmt.Println("Both players need different names.")
+		default:
+			return left, right, true
+		}
+	}
+}
+
+// pickPlayers asks for a 1v1 or 2v2 game and the usernames of the other
+// players, and returns them in the order expected by api.CreateGame.
+func pickPlayers(reader *bufio.Reader, me string) ([]string, bool) {
+	fmt.Println("1. 1 vs 1")
+	fmt.Println("2. 2 vs 2 (you and a teammate against two opponents)")
+	fmt.Print("Choose the game type (1-2): ")
+	kind, _ := reader.ReadString('\n')
+
+	var prompts []string
+	switch strings.TrimSpace(kind) {
+	case "1":
+		prompts = []string{"Enter your opponent username : "}
+	case "2":
+		// Even positions, starting with the host, make up the left team.
+		prompts = []string{
+			"Enter the first opponent username : ",
+			"Enter your teammate username : ",
+			"Enter the second opponent username : ",
+		}
+	default:
+		return nil, false
+	}
+
+	seen := map[string]bool{me: true}
+	players := make([]string, 0, len(prompts))
+	for _, prompt := range prompts {
+		fmt.Print(prompt)
+		name, _ := reader.ReadString('\n')
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			fmt.Println("Every player must have a distinct, non-empty username.")
+			fmt.Println("Press Enter to continue...")
+			reader.ReadBytes('\n')
+			return nil, false
+		}
+		seen[name] = true
+		players = append(players, name)
+	}
+	return players, true
+}
+
+func getCredentials() (string, error) {
+	reader := bufio.NewReader(os.Stdin)
+
+	fmt.Print("Username: ")
+	username, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	username = strings.TrimSpace(username)
+
+	return username, nil
+}
+
+// describeGame summarizes a listed game on one line, from the point of view
+// of username.
+func describeGame(g api.GameDetail, username string) string {
+	if errors.Is(g.Err, api.ErrNotFound) {
+		return fmt.Sprintf("[game %s has already ended]", g.ID)
+	}
+	if g.Err != nil {
+		return fmt.Sprintf("[error fetching game %s]", g.ID)
+	}
+
+	state := g.State
+	if len(state.Players) < 2 {
+		return fmt.Sprintf("[game %s has no opponent]", g.ID)
+	}
+	host, opponents := "Host", "Opponent"
+	if len(state.Players) > 2 {
+		host, opponents = "Host team", "Opponents"
+	}
+	// Team scores are kept on the first player of each team.
+	line := fmt.Sprintf("%s: %-10s  %s: %-10s  Score: %d-%d",
+		host, pong.TeamName(state.Players, 0, 0),
+		opponents, pong.TeamName(state.Players, 1, 0),
+		state.Players[0].Player.Score,
+		state.Players[1].Player.Score,
+	)
+	if state.Pause {
+		line += "  [paused]"
+	}
+	if slot := state.PlayerIndex(username); slot >= 0 {
+		line += fmt.Sprintf("  (you are player %d of %d)", slot+1, len(state.Players))
+	}
+	return line
+}
+
+// sessionExpiryWarning is how long before the token expires the user is
+// warned about it.
+const sessionExpiryWarning = 5 * time.Minute
+
+// sessionStatus tells who is logged in and when the session expires.
+func sessionStatus(client *api.Client) string {
+	claims, err := client.Claims()
+	if err != nil {
+		return fmt.Sprintf(" Logged in as %s", client.GetUsername())
+	}
+	status := fmt.Sprintf(" Logged in as %s", claims.Username)
+	if !claims.Expires() {
+		return status + ", session does not expire"
+	}
+
+	left := claims.ExpiresIn(time.Now())
+	if left <= 0 {
+		return status + ", session expired: you will be logged in again automatically"
+	}
+	status += fmt.Sprintf(", session expires in %d minutes", int(left.Round(time.Minute).Minutes()))
+	if left < sessionExpiryWarning {
+		status += "\n Warning: your session is about to expire, it will be renewed automatically"
+	}
+	return status
+}
+
+// describeError turns an API error into a message telling the user what
+// went wrong and what to do about it. notFound explains a 404 for the call
+// at hand.
+func describeError(err error, notFound string) string {
+	var apiErr *api.APIError
+	var certErr *api.CertificateChangedError
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "Canceled."
+	case errors.Is(err, context.DeadlineExceeded):
+		return "The server took too long to answer. Try again later."
+	case errors.As(err, &certErr):
+		return certErr.Error()
+	case errors.Is(err, api.ErrUnauthorized):
+		return "Your session is no longer valid. Log out and log in again."
+	case errors.Is(err, api.ErrNotFound):
+		return notFound
+	case errors.Is(err, api.ErrBadRequest) && errors.As(err, &apiErr):
+		return fmt.Sprintf("The server rejected the request: %s", apiErr.Message())
+	case errors.As(err, &apiErr):
+		return fmt.Sprintf("The server failed (%d): %s", apiErr.StatusCode, apiErr.Message())
+	default:
+		return fmt.Sprintf("Cannot reach the server. Check that it is running and that -server points to it.\n (%v)", err)
+	}
+}
+
+// interruptible returns a context canceled by Ctrl+C, so that a hung
+// request brings the user back to the menu instead of freezing the CLI.
+// Calling the cancel function restores the default Ctrl+C behavior.
+func interruptible() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
+
+func clearScreen() {
+	fmt.Print("\033[H\033[2J")
+}
+
+// calibrate measures the key auto-repeat of the terminal and saves it in
+// the config file, so that held keys are told apart from released ones.
+func calibrate(path string) error {
+	if path == "" {
+		p, err := config.Path()
+		if err != nil {
+			return err
+		}
+		path = p
+	}
+	repeat, err := pong.CalibrateKeyRepeat()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+	cfg.KeyRepeatDelay = config.Duration(repeat.Delay)
+	cfg.KeyRepeatInterval = config.Duration(repeat.Interval)
+	if err := config.Save(path, cfg); err != nil {
+		return err
+	}
+	fmt.Printf("Keys repeat after %s, then every %s. Saved to %s\n", repeat.Delay, repeat.Interval, path)
+	return nil
+}
@@ -4,19 +4,54 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
 )
 
 type GameState struct {
 	ID      string       `json:"id"`
-	Pause   bool         `json:"pause"`
+	Pause   bool         `json:"paused"`
 	Players []GamePlayer `json:"players"`
 	Ball    Ball         `json:"ball"`
 }
 
+// GameSummary is an entry of the user's game list.
+type GameSummary struct {
+	ID string `json:"id"`
+}
+
+// MaxPlayers is the number of players of a 2v2 game.
+const MaxPlayers = 4
+
+// Team returns the side of the player at index i: 0 for the left team,
+// which includes the host, and 1 for the right one.
+func Team(i int) int {
+	return i % 2
+}
+
+// PlayerIndex returns the position of username in Players, or -1.
+func (g *GameState) PlayerIndex(username string) int {
+	for i, p := range g.Players {
+		if p.Player.Username == username {
+			return i
+		}
+	}
+	return -1
+}
+
 type GamePlayer struct {
 	Player Player `json:"player"`
 	Paddle Paddle `json:"paddle"`
@@ -41,9 +76,22 @@ type Ball struct {
 
 type Client struct {
 	baseURL    string
+	base       *url.URL
 	httpClient *http.Client
-	token      string
 	username   string
+
+	mu             sync.RWMutex
+	token          string
+	onTokenRefresh func(token string)
+
+	rootCAs    *x509.CertPool
+	knownHosts *KnownHosts
+	insecure   bool
+	tlsConfig  *tls.Config
+
+	transport transportOptions
+	netDialer *net.Dialer
+	retry     RetryPolicy
 }
 
 type AuthRequest struct {
@@ -54,254 +102,303 @@ type AuthResponse struct {
 	Token string `json:"token"`
 }
 
-func NewClient(serverURL string, token string, username string) *Client {
-	return &Client{
-		baseURL:    serverURL,
-		httpClient: &http.Client{},
-		token:      token,
-		username:   username,
-	}
-}
-
-// CreateGame creates a new game and returns the initial game state.
-// If opponent is not empty, it will be used to specify the opponent's username.
-func (c *Client) CreateGame(opponent string) (*GameState, error) {
-	// Prepare the request body
-	var reqBody []byte
-	var err error
-	opponents := []string{opponent}
-
-	if opponent != "" {
-		reqBody, err = json.Marshal(map[string][]string{"opponents": opponents})
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal request body: %w", err)
-		}
-	} else {
-		reqBody = []byte("{}")
-	}
-
-	req, err := http.NewRequestWithContext(
-		context.Background(),
-		http.MethodPost,
-		fmt.Sprintf("%s/api/game", c.baseURL),
-		bytes.NewBuffer(reqBody),
-	)
+// NewClient returns a client for the API served at serverURL. Both the REST
+// and the WebSocket endpoints are derived from it, so serverURL may carry a
+// path prefix when the API sits behind a reverse proxy.
+//
+// Server certificates are verified against the system roots unless the
+// options say otherwise.
+//
+// Every call takes a context to cancel it; calls are additionally bounded by
+// the client timeout (see WithTimeout). GET calls are retried on transient
+// failures (see RetryPolicy).
+func NewClient(serverURL string, token string, username string, opts ...Option) (*Client, error) {
+	base, err := url.Parse(strings.TrimRight(serverURL, "/"))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("invalid server URL %q: %w", serverURL, err)
 	}
-
-	// Rest of the existing CreateGame implementation remains the same...
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.token)
-
-	c.httpClient = &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
-		},
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("invalid server URL %q: scheme must be http or https", serverURL)
 	}
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+	if base.Host == "" {
+		return nil, fmt.Errorf("invalid server URL %q: missing host", serverURL)
 	}
-	defer resp.Body.Close()
+	base.RawQuery = ""
+	base.Fragment = ""
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+	c := &Client{
+		baseURL:   base.String(),
+		base:      base,
+		token:     token,
+		username:  username,
+		transport: defaultTransportOptions(),
+		retry:     DefaultRetryPolicy(),
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
 	}
-
-	var game GameState
-	if err := json.Unmarshal(body, &game); err != nil {
-		return nil, fmt.Errorf("failed to decode game state: %w", err)
+	c.tlsConfig = c.buildTLSConfig()
+	c.netDialer = &net.Dialer{Timeout: c.transport.dialTimeout, KeepAlive: 30 * time.Second}
+
+	rt := c.transport.roundTripper
+	if rt == nil {
+		rt = &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			DialContext:         c.netDialer.DialContext,
+			TLSClientConfig:     c.tlsConfig,
+			TLSHandshakeTimeout: c.transport.dialTimeout,
+			ForceAttemptHTTP2:   true,
+			MaxIdleConns:        c.transport.maxIdleConns,
+			MaxIdleConnsPerHost: c.transport.maxIdleConns,
+			IdleConnTimeout:     c.transport.idleConnTimeout,
+		}
 	}
-
-	return &game, nil
+	// The same client, and therefore the same pool of keep-alive
+	// connections and TLS sessions, serves every call.
+	c.httpClient = &http.Client{
+		Transport: rt,
+		Timeout:   c.transport.timeout,
+	}
+	return c, nil
 }
 
-func (c *Client) GetGameState(gameID string) (*GameState, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/game/%s", c.baseURL, gameID), nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
+// Dialer returns a WebSocket dialer sharing the client's TLS configuration,
+// proxy settings and dial timeouts.
+func (c *Client) Dialer() *websocket.Dialer {
+	return &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		NetDialContext:   c.netDialer.DialContext,
+		TLSClientConfig:  c.TLSConfig(),
+		HandshakeTimeout: c.transport.dialTimeout,
 	}
+}
 
-	req.Header.Add("Authorization", "Bearer "+c.token)
+// CloseIdleConnections closes the keep-alive connections that are not in
+// use, e.g. before exiting.
+func (c *Client) CloseIdleConnections() {
+	c.httpClient.CloseIdleConnections()
+}
 
-	c.httpClient = &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
-		},
-	}
+// BaseURL returns the normalized server URL, without a trailing slash.
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get game state: %v", err)
+// RealtimeURL returns the WebSocket endpoint of gameID. The scheme follows
+// the base URL (http becomes ws, https becomes wss) and its path prefix is
+// kept. gameID is escaped like in the other game calls, so it stays a
+// single path segment. The token is passed as a query parameter as the
+// backend expects.
+func (c *Client) RealtimeURL(gameID string) string {
+	u := *c.base
+	if u.Scheme == "https" {
+		u.Scheme = "wss"
+	} else {
+		u.Scheme = "ws"
 	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
+	u.RawPath = path.Join("/", u.EscapedPath(), "ws/game") + "/" + url.PathEscape(gameID)
+	u.Path = path.Join("/", u.Path, "ws/game") + "/" + gameID
+	u.RawQuery = url.Values{"token": {c.GetToken()}}.Encode()
+	return u.String()
+}
 
-	if resp.StatusCode == http.StatusNotFound {
-		var errorResp struct {
-			Error string `json:"error"`
-		}
-		if err := json.Unmarshal(body, &errorResp); err == nil {
-			return nil, fmt.Errorf("game not found: %s", errorResp.Error)
-		}
-		return nil, fmt.Errorf("game not found")
-	}
+// Origin returns the value sent in the Origin header of WebSocket
+// handshakes, i.e. the scheme and host of the base URL.
+func (c *Client) Origin() string {
+	return c.base.Scheme + "://" + c.base.Host
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+// CreateGame creates a new game and returns the initial game state.
+//
+// The players are the user followed by opponents, in that order, and the
+// backend puts even positions on the left team and odd ones on the right:
+// a 1v1 game takes one opponent, and a 2v2 game takes the first opponent,
+// the user's teammate and the second opponent. Without opponents the user
+// plays against themselves.
+func (c *Client) CreateGame(ctx context.Context, opponents ...string) (*GameState, error) {
+	body := map[string][]string{}
+	if len(opponents) > 0 {
+		body["opponents"] = opponents
 	}
 
 	var game GameState
-	if err := json.Unmarshal(body, &game); err != nil {
-		return nil, fmt.Errorf("failed to decode game state: %v", err)
+	if err := c.call(ctx, http.MethodPost, "/api/game", body, &game); err != nil {
+		return nil, err
 	}
 	return &game, nil
 }
 
-func (c *Client) Authenticate(username string) (string, error) {
-	payload := AuthRequest{Username: username}
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal auth payload: %w", err)
-	}
-
-	req, err := http.NewRequestWithContext(
-		context.Background(),
-		http.MethodPost,
-		fmt.Sprintf("%s/api/login", c.baseURL),
-		bytes.NewBuffer(body),
-	)
-	if err != nil {
-		return "", fmt.Errorf("failed to create auth request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	c.httpClient = &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
-		},
-	}
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to send auth request: %w", err)
+func (c *Client) GetGameState(ctx context.Context, gameID string) (*GameState, error) {
+	var game GameState
+	if err := c.call(ctx, http.MethodGet, "/api/game/"+url.PathEscape(gameID), nil, &game); err != nil {
+		return nil, err
 	}
-	defer resp.Body.Close()
+	return &game, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("authentication failed: %s", resp.Status)
-	}
+func (c *Client) Authenticate(ctx context.Context, username string) (string, error) {
 	var authResp AuthResponse
-	if err := json.NewDecoder(resp.Body).Decode(&authResp); err != nil {
-		return "", fmt.Errorf("failed to decode auth response: %w", err)
+	if err := c.call(ctx, http.MethodPost, "/api/login", AuthRequest{Username: username}, &authResp); err != nil {
+		return "", err
 	}
-
 	return authResp.Token, nil
 }
 
 func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.token = token
 }
 
 func (c *Client) GetToken() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.token
 }
 
+// CurrentUser returns the user the token was issued to, which also checks
+// that the token is still accepted by the server.
+func (c *Client) CurrentUser(ctx context.Context) (*User, error) {
+	var user User
+	if err := c.call(ctx, http.MethodGet, "/api/user", nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (c *Client) GetUsername() string {
 	return c.username
 }
 
-func (c *Client) ListGames() ([]GameState, error) {
-	req, err := http.NewRequest(
-		http.MethodGet,
-		fmt.Sprintf("%s/api/user/games", c.baseURL),
-		nil,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+// ListGames returns the games the user takes part in. Use GetGameState or
+// ListGamesDetailed for their state.
+func (c *Client) ListGames(ctx context.Context) ([]GameSummary, error) {
+	var games []GameSummary
+	if err := c.call(ctx, http.MethodGet, "/api/user/games", nil, &games); err != nil {
+		return nil, err
 	}
+	return games, nil
+}
 
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.GetToken())
-
-	c.httpClient = &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
-		},
+// Pause stops the game for every player and returns its state.
+func (c *Client) Pause(ctx context.Context, gameID string) (*GameState, error) {
+	if gameID == "" {
+		return nil, nil
 	}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+	var game GameState
+	if err := c.call(ctx, http.MethodPost, "/api/game/"+url.PathEscape(gameID)+"/pause", nil, &game); err != nil {
+		return nil, err
 	}
-	defer resp.Body.Close()
+	return &game, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+// Unpause resumes the game for every player and returns its state.
+func (c *Client) Unpause(ctx context.Context, gameID string) (*GameState, error) {
+	if gameID == "" {
+		return nil, nil
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+	var game GameState
+	if err := c.call(ctx, http.MethodPost, "/api/game/"+url.PathEscape(gameID)+"/unpause", nil, &game); err != nil {
+		return nil, err
 	}
+	return &game, nil
+}
 
-	var games []GameState
-	if err := json.Unmarshal(body, &games); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w. Body: %s", err, string(body))
+// call sends a JSON request to path, relative to the base URL, and decodes
+// a successful JSON response into out. Non-2xx responses are returned as
+// *APIError. Safe calls are retried according to the retry policy, and a
+// call rejected because the token expired is sent again after logging in
+// anew with the client's username.
+func (c *Client) call(ctx context.Context, method, path string, in, out any) error {
+	err := c.callRetrying(ctx, method, path, in, out)
+	if !errors.Is(err, ErrUnauthorized) || path == "/api/login" || c.username == "" {
+		return err
 	}
 
-	return games, nil
+	log.Printf("%s %s: token rejected, re-authenticating as %s", method, path, c.username)
+	token, authErr := c.Authenticate(ctx, c.username)
+	if authErr != nil {
+		return fmt.Errorf("%w (re-authentication failed: %v)", err, authErr)
+	}
+	c.SetToken(token)
+	if c.onTokenRefresh != nil {
+		c.onTokenRefresh(token)
+	}
+	return c.callRetrying(ctx, method, path, in, out)
 }
 
-func (c *Client) Unpause(gameID string) (*GameState, error) {
-	if gameID == "" {
-		return nil, nil
+func (c *Client) callRetrying(ctx context.Context, method, path string, in, out any) error {
+	var body []byte
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s %s request body: %w", method, path, err)
+		}
+		body = b
 	}
 
-	url := fmt.Sprintf("%s/api/game/%s/unpause", c.baseURL, gameID)
-	req, err := http.NewRequest(http.MethodPost, url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create unpause request: %w", err)
+	attempts := 1
+	key := idempotencyKeyFrom(ctx)
+	if method == http.MethodGet || key != "" {
+		attempts = c.retry.MaxAttempts
 	}
-	req.Header.Set("Authorization", "Bearer "+c.token)
 
-	c.httpClient = &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
+	var err error
+	for n := 1; ; n++ {
+		err = c.send(ctx, method, path, body, key, out)
+		if err == nil || n >= attempts || !c.retry.retryable(ctx, err) {
+			break
+		}
+		d := c.retry.delay(n)
+		log.Printf("%s %s failed (attempt %d/%d): %v; retrying in %s", method, path, n, attempts, err, d)
+		if sleepErr := sleep(ctx, d); sleepErr != nil {
+			break
+		}
+	}
+	return err
+}
+
+// send performs a single attempt of call.
+func (c *Client) send(ctx context.Context, method, path string, body []byte, idempotencyKey string, out any) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create %s %s request: %w", method, path, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token := c.GetToken(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
 	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send unpause request: %w", err)
+		return fmt.Errorf("failed to send %s %s request: %w", method, path, err)
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read unpause response: %w", err)
+		return fmt.Errorf("failed to read %s %s response: %w", method, path, err)
 	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unpause failed: status %d, body: %s", resp.StatusCode, string(body))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newAPIError(method, path, resp.StatusCode, respBody)
 	}
 
-	var game GameState
-	if err := json.Unmarshal(body, &game); err != nil {
-		return nil, fmt.Errorf("failed to decode GameState: %w (body: %s)", err, string(body))
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to decode %s %s response: %w (body: %s)", method, path, err, respBody)
 	}
-	return &game, nil
+	return nil
 }
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const benchGameState = `{"id":"g1","paused":false,"players":[` +
+	`{"player":{"username":"alice","score":1,"won":false},"paddle":{"y":200}},` +
+	`{"player":{"username":"bob","score":2,"won":false},"paddle":{"y":150}}],` +
+	`"ball":{"x":500,"y":250,"vx":5,"vy":1}}`
+
+// newBenchServer starts a TLS server answering every request with a game
+// state and returns it with a CA file trusting its certificate.
+func newBenchServer(b *testing.B) (*httptest.Server, string) {
+	b.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(benchGameState))
+	}))
+	b.Cleanup(srv.Close)
+
+	caFile := filepath.Join(b.TempDir(), "ca.pem")
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(block), 0o600); err != nil {
+		b.Fatal(err)
+	}
+	return srv, caFile
+}
+
+// freshTransport reproduces the previous behavior of the client: a new
+// transport, hence a new TCP connection and TLS handshake, for every call.
+type freshTransport struct {
+	tlsConfig *tls.Config
+}
+
+func (f freshTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t := &http.Transport{TLSClientConfig: f.tlsConfig, DisableKeepAlives: true}
+	defer t.CloseIdleConnections()
+	return t.RoundTrip(req)
+}
+
+func BenchmarkGetGameState(b *testing.B) {
+	srv, caFile := newBenchServer(b)
+	ctx := context.Background()
+
+	b.Run("shared-transport", func(b *testing.B) {
+		c, err := NewClient(srv.URL, "token", "alice", WithCAFile(caFile))
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer c.CloseIdleConnections()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := c.GetGameState(ctx, "g1"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("transport-per-call", func(b *testing.B) {
+		c, err := NewClient(srv.URL, "token", "alice", WithCAFile(caFile))
+		if err != nil {
+			b.Fatal(err)
+		}
+		c.httpClient.Transport = freshTransport{tlsConfig: c.TLSConfig()}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := c.GetGameState(ctx, "g1"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,466 @@
+package api_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"clipongo/pkg/api"
+	"clipongo/pkg/apitest"
+)
+
+func newClient(t *testing.T, srv *apitest.Server, username string, opts ...api.Option) *api.Client {
+	t.Helper()
+	opts = append([]api.Option{api.WithRetryPolicy(api.RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    time.Millisecond,
+		RetryOn:     api.DefaultRetryPolicy().RetryOn,
+	})}, opts...)
+	c, err := api.NewClient(srv.URL, "", username, opts...)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return c
+}
+
+// login returns a client authenticated as username.
+func login(t *testing.T, srv *apitest.Server, username string, opts ...api.Option) *api.Client {
+	t.Helper()
+	c := newClient(t, srv, username, opts...)
+	token, err := c.Authenticate(context.Background(), username)
+	if err != nil {
+		t.Fatalf("Authenticate(%q): %v", username, err)
+	}
+	c.SetToken(token)
+	return c
+}
+
+func TestNewClientEndpoints(t *testing.T) {
+	tests := []struct {
+		server   string
+		base     string
+		realtime string
+		origin   string
+	}{
+		{"https://localhost:1443", "https://localhost:1443", "wss://localhost:1443/ws/game/g1?token=tok", "https://localhost:1443"},
+		{"http://pong.test/", "http://pong.test", "ws://pong.test/ws/game/g1?token=tok", "http://pong.test"},
+		{"https://pong.test/prefix/", "https://pong.test/prefix", "wss://pong.test/prefix/ws/game/g1?token=tok", "https://pong.test"},
+	}
+	for _, tt := range tests {
+		c, err := api.NewClient(tt.server, "tok", "alice")
+		if err != nil {
+			t.Fatalf("NewClient(%q): %v", tt.server, err)
+		}
+		if got := c.BaseURL(); got != tt.base {
+			t.Errorf("BaseURL() = %q, want %q", got, tt.base)
+		}
+		if got := c.RealtimeURL("g1"); got != tt.realtime {
+			t.Errorf("RealtimeURL() = %q, want %q", got, tt.realtime)
+		}
+		if got := c.Origin(); got != tt.origin {
+			t.Errorf("Origin() = %q, want %q", got, tt.origin)
+		}
+	}
+
+	c, err := api.NewClient("https://pong.test/prefix", "tok", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[string]string{
+		"../x": "wss://pong.test/prefix/ws/game/..%2Fx?token=tok",
+		"a/b":  "wss://pong.test/prefix/ws/game/a%2Fb?token=tok",
+		"a b":  "wss://pong.test/prefix/ws/game/a%20b?token=tok",
+	} {
+		if got := c.RealtimeURL(id); got != want {
+			t.Errorf("RealtimeURL(%q) = %q, want %q", id, got, want)
+		}
+	}
+
+	for _, bad := range []string{"localhost:1443", "ftp://pong.test", "https://"} {
+		if _, err := api.NewClient(bad, "", ""); err == nil {
+			t.Errorf("NewClient(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestAuthenticateAndCurrentUser(t *testing.T) {
+	srv := apitest.NewServer()
+	defer srv.Close()
+	srv.SetTokenTTL(time.Hour)
+
+	c := login(t, srv, "alice")
+	user, err := c.CurrentUser(context.Background())
+	if err != nil {
+		t.Fatalf("CurrentUser: %v", err)
+	}
+	if user.Username != "alice" {
+		t.Errorf("CurrentUser().Username = %q, want alice", user.Username)
+	}
+
+	claims, err := c.Claims()
+	if err != nil {
+		t.Fatalf("Claims: %v", err)
+	}
+	if claims.Username != "alice" || !claims.Expires() {
+		t.Errorf("Claims() = %+v, want alice with an expiry", claims)
+	}
+	if left := claims.ExpiresIn(time.Now()); left < 59*time.Minute || left > time.Hour {
+		t.Errorf("ExpiresIn() = %s, want about an hour", left)
+	}
+
+	_, err = c.Authenticate(context.Background(), "much-too-long-name")
+	if !errors.Is(err, api.ErrBadRequest) {
+		t.Errorf("Authenticate(long name) error = %v, want ErrBadRequest", err)
+	}
+}
+
+func TestUnauthorizedWithoutUsername(t *testing.T) {
+	srv := apitest.NewServer()
+	defer srv.Close()
+
+	c, err := api.NewClient(srv.URL, "bogus", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.CurrentUser(context.Background())
+	if !errors.Is(err, api.ErrUnauthorized) {
+		t.Fatalf("CurrentUser error = %v, want ErrUnauthorized", err)
+	}
+	var apiErr *api.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("error %v is not an *APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusUnauthorized || apiErr.Endpoint != "GET /api/user" || apiErr.Message() == "" {
+		t.Errorf("APIError = %+v", apiErr)
+	}
+}
+
+func TestExpiredToken(t *testing.T) {
+	srv := apitest.NewServer()
+	defer srv.Close()
+	srv.SetTokenTTL(time.Millisecond)
+
+	c, err := api.NewClient(srv.URL, srv.Login("alice"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := c.CurrentUser(context.Background()); !errors.Is(err, api.ErrUnauthorized) {
+		t.Errorf("CurrentUser with an expired token error = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestReauthenticateOnUnauthorized(t *testing.T) {
+	srv := apitest.NewServer()
+	defer srv.Close()
+
+	var refreshed string
+	c := login(t, srv, "alice", api.WithTokenRefresh(func(token string) { refreshed = token }))
+	old := c.GetToken()
+	srv.RevokeTokens()
+
+	if _, err := c.ListGames(context.Background()); err != nil {
+		t.Fatalf("ListGames after revocation: %v", err)
+	}
+	if c.GetToken() == old || refreshed != c.GetToken() {
+		t.Errorf("token not refreshed: old %q, now %q, callback got %q", old, c.GetToken(), refreshed)
+	}
+}
+
+func TestCreateGame(t *testing.T) {
+	srv := apitest.NewServer()
+	defer srv.Close()
+	c := login(t, srv, "alice")
+	ctx := context.Background()
+
+	game, err := c.CreateGame(ctx, "bob")
+	if err != nil {
+		t.Fatalf("CreateGame(bob): %v", err)
+	}
+	if got := usernames(game); !slices.Equal(got, []string{"alice", "bob"}) {
+		t.Errorf("players = %v, want [alice bob]", got)
+	}
+	if !game.Pause {
+		t.Error("new game is not paused")
+	}
+
+	game, err = c.CreateGame(ctx, "bob", "carol", "dave")
+	if err != nil {
+		t.Fatalf("CreateGame 2v2: %v", err)
+	}
+	if got := usernames(game); !slices.Equal(got, []string{"alice", "bob", "carol", "dave"}) {
+		t.Errorf("players = %v, want [alice bob carol dave]", got)
+	}
+	if api.Team(game.PlayerIndex("carol")) != api.Team(game.PlayerIndex("alice")) {
+		t.Error("carol is not on alice's team")
+	}
+
+	_, err = c.CreateGame(ctx, "bob", "carol")
+	var apiErr *api.APIError
+	if !errors.As(err, &apiErr) || apiErr.Body.Error != "Invalid number of opponents" {
+		t.Errorf("CreateGame with 2 opponents error = %v, want the server message", err)
+	}
+}
+
+func TestCreateGameIsNotRetried(t *testing.T) {
+	srv := apitest.NewServer()
+	defer srv.Close()
+	c := login(t, srv, "alice")
+
+	srv.Fail(http.MethodPost, "/api/game", http.StatusServiceUnavailable, 1)
+	if _, err := c.CreateGame(context.Background(), "bob"); err == nil {
+		t.Fatal("CreateGame succeeded, want the 503")
+	}
+
+	srv.Fail(http.MethodPost, "/api/game", http.StatusServiceUnavailable, 1)
+	ctx := api.WithIdempotencyKey(context.Background(), "key-1")
+	if _, err := c.CreateGame(ctx, "bob"); err != nil {
+		t.Fatalf("CreateGame with idempotency key: %v", err)
+	}
+}
+
+func TestGetGameState(t *testing.T) {
+	srv := apitest.NewServer()
+	defer srv.Close()
+	c := login(t, srv, "alice")
+	created := srv.CreateGame("alice", "bob")
+
+	game, err := c.GetGameState(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("GetGameState: %v", err)
+	}
+	if game.ID != created.ID || len(game.Players) != 2 {
+		t.Errorf("GetGameState() = %+v, want %+v", game, created)
+	}
+
+	_, err = c.GetGameState(context.Background(), "missing")
+	if !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("GetGameState(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestGetGameStateRetries(t *testing.T) {
+	srv := apitest.NewServer()
+	defer srv.Close()
+	c := login(t, srv, "alice")
+	created := srv.CreateGame("alice", "bob")
+	path := "/api/game/" + created.ID
+
+	srv.Fail(http.MethodGet, path, http.StatusBadGateway, 2)
+	if _, err := c.GetGameState(context.Background(), created.ID); err != nil {
+		t.Fatalf("GetGameState after 2 failures: %v", err)
+	}
+
+	srv.Fail(http.MethodGet, path, http.StatusBadGateway, 3)
+	if _, err := c.GetGameState(context.Background(), created.ID); err == nil {
+		t.Fatal("GetGameState succeeded after 3 failures, want an error")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.GetGameState(ctx, created.ID); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetGameState with canceled context error = %v, want context.Canceled", err)
+	}
+}
+
+// failingTransport answers every request with err, or with body when err
+// is nil, and counts the attempts.
+type failingTransport struct {
+	err      error
+	body     string
+	attempts atomic.Int32
+}
+
+func (f *failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.attempts.Add(1)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(f.body)),
+		Request:    req,
+	}, nil
+}
+
+func TestRetryOnlyTransportErrors(t *testing.T) {
+	srv := apitest.NewServer()
+	defer srv.Close()
+	tests := []struct {
+		name     string
+		err      error
+		body     string
+		attempts int32
+	}{
+		{"connection reset", &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, "", 3},
+		{"unexpected EOF", io.ErrUnexpectedEOF, "", 3},
+		{"unknown authority", &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}, "", 1},
+		{"bad json", nil, "{not json", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &failingTransport{err: tt.err, body: tt.body}
+			c := newClient(t, srv, "alice", api.WithRoundTripper(transport))
+			if _, err := c.GetGameState(context.Background(), "1"); err == nil {
+				t.Fatal("GetGameState succeeded, want an error")
+			}
+			if got := transport.attempts.Load(); got != tt.attempts {
+				t.Errorf("%d attempts, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestListGames(t *testing.T) {
+	srv := apitest.NewServer()
+	defer srv.Close()
+	c := login(t, srv, "alice")
+
+	games, err := c.ListGames(context.Background())
+	if err != nil {
+		t.Fatalf("ListGames: %v", err)
+	}
+	if len(games) != 0 {
+		t.Errorf("ListGames() = %v, want none", games)
+	}
+
+	g1 := srv.CreateGame("alice", "bob")
+	g2 := srv.CreateGame("carol", "alice")
+	srv.CreateGame("carol", "dave")
+
+	details, err := c.ListGamesDetailed(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("ListGamesDetailed: %v", err)
+	}
+	ids := map[string]bool{}
+	for _, d := range details {
+		if d.Err != nil {
+			t.Errorf("game %s: %v", d.ID, d.Err)
+			continue
+		}
+		if d.State.ID != d.ID {
+			t.Errorf("game %s has the state of %s", d.ID, d.State.ID)
+		}
+		ids[d.ID] = true
+	}
+	if len(ids) != 2 || !ids[g1.ID] || !ids[g2.ID] {
+		t.Errorf("ListGamesDetailed() = %v, want %s and %s", ids, g1.ID, g2.ID)
+	}
+}
+
+func TestPauseAndUnpause(t *testing.T) {
+	srv := apitest.NewServer()
+	defer srv.Close()
+	c := login(t, srv, "alice")
+	created := srv.CreateGame("alice", "bob")
+	ctx := context.Background()
+
+	game, err := c.Unpause(ctx, created.ID)
+	if err != nil || game.Pause {
+		t.Fatalf("Unpause() = %+v, %v, want a running game", game, err)
+	}
+	game, err = c.Pause(ctx, created.ID)
+	if err != nil || !game.Pause {
+		t.Fatalf("Pause() = %+v, %v, want a paused game", game, err)
+	}
+	if state, _ := srv.Game(created.ID); !state.Pause {
+		t.Error("server game is not paused")
+	}
+	if _, err := c.Pause(ctx, "missing"); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("Pause(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func usernames(game *api.GameState) []string {
+	var names []string
+	for _, p := range game.Players {
+		names = append(names, p.Player.Username)
+	}
+	return names
+}
+
+func TestTLSVerification(t *testing.T) {
+	srv := apitest.NewTLSServer()
+	defer srv.Close()
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	c, err := api.NewClient(srv.URL, "", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Authenticate(ctx, "alice"); err == nil {
+		t.Error("Authenticate trusted a self-signed certificate by default")
+	}
+
+	caFile := filepath.Join(dir, "ca.pem")
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, pemBytes, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, err = api.NewClient(srv.URL, "", "alice", api.WithCAFile(caFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Authenticate(ctx, "alice"); err != nil {
+		t.Errorf("Authenticate with CA file: %v", err)
+	}
+}
+
+func TestTrustOnFirstUse(t *testing.T) {
+	srv := apitest.NewTLSServer()
+	defer srv.Close()
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	ctx := context.Background()
+
+	var pinned []string
+	c, err := api.NewClient(srv.URL, "", "alice", api.WithKnownHosts(knownHosts, func(host, fingerprint string) {
+		pinned = append(pinned, fingerprint)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if _, err := c.Authenticate(ctx, "alice"); err != nil {
+			t.Fatalf("Authenticate: %v", err)
+		}
+	}
+	want := api.Fingerprint(srv.Certificate())
+	if !slices.Equal(pinned, []string{want}) {
+		t.Errorf("pinned %v, want only %s", pinned, want)
+	}
+
+	// Pretend the server used to present another certificate.
+	data, err := os.ReadFile(knownHosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := strings.Replace(string(data), want, "SHA256:previous", 1)
+	if err := os.WriteFile(knownHosts, []byte(changed), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, err = api.NewClient(srv.URL, "", "alice", api.WithKnownHosts(knownHosts, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Authenticate(ctx, "alice")
+	var certErr *api.CertificateChangedError
+	if !errors.As(err, &certErr) || certErr.Got != want {
+		t.Errorf("Authenticate with changed certificate error = %v, want a CertificateChangedError", err)
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors matched by *APIError through errors.Is.
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrNotFound     = errors.New("not found")
+)
+
+// PongError is the error body sent by the backend. Errors raised by Fastify
+// itself (validation, JWT) also carry a message.
+type PongError struct {
+	Error   string `json:"error"`
+	Message string `json:"message,omitempty"`
+}
+
+// APIError is returned when the server answers with a non-2xx status.
+type APIError struct {
+	StatusCode int
+	// Endpoint is the method and path of the call, e.g. "GET /api/game/42".
+	Endpoint string
+	// Body is the decoded error body, empty if the server sent none.
+	Body PongError
+	// Raw is the response body as received.
+	Raw string
+}
+
+func newAPIError(method, path string, status int, body []byte) *APIError {
+	e := &APIError{
+		StatusCode: status,
+		Endpoint:   method + " " + path,
+		Raw:        string(body),
+	}
+	// A body that is not a PongError is kept in Raw only.
+	_ = json.Unmarshal(body, &e.Body)
+	return e
+}
+
+// Message returns the most specific explanation sent by the server.
+func (e *APIError) Message() string {
+	switch {
+	case e.Body.Message != "":
+		return e.Body.Message
+	case e.Body.Error != "":
+		return e.Body.Error
+	default:
+		return strings.TrimSpace(e.Raw)
+	}
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s: %d %s", e.Endpoint, e.StatusCode, http.StatusText(e.StatusCode))
+	if m := e.Message(); m != "" {
+		msg += ": " + m
+	}
+	return msg
+}
+
+// Is reports whether the status code matches one of the sentinel errors.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultListWorkers is the number of games fetched at once by
+// ListGamesDetailed.
+const DefaultListWorkers = 4
+
+// GameDetail is a listed game with either its state or the error met
+// fetching it.
+type GameDetail struct {
+	GameSummary
+	State *GameState
+	Err   error
+}
+
+// ListGamesDetailed lists the user's games and fetches their states
+// concurrently, at most workers at a time (DefaultListWorkers if workers is
+// not positive). The result keeps the order of ListGames. A game that
+// cannot be fetched, e.g. because it ended meanwhile, only sets its Err.
+func (c *Client) ListGamesDetailed(ctx context.Context, workers int) ([]GameDetail, error) {
+	games, err := c.ListGames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if workers <= 0 {
+		workers = DefaultListWorkers
+	}
+	workers = min(workers, len(games))
+
+	details := make([]GameDetail, len(games))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				details[i].State, details[i].Err = c.GetGameState(ctx, details[i].ID)
+			}
+		}()
+	}
+
+	for i, g := range games {
+		details[i].GameSummary = g
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return details, ctx.Err()
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type User struct {
+	Username string `json:"username"`
+}
+
+// Claims are the fields of the session token the CLI cares about.
+type Claims struct {
+	Username string
+	IssuedAt time.Time
+	// ExpiresAt is zero when the token does not expire.
+	ExpiresAt time.Time
+}
+
+// ParseClaims decodes the payload of a JWT. The signature is not checked:
+// only the server can do that, the claims are used for display and to
+// anticipate expiry.
+func ParseClaims(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token: expected 3 parts, got %d", len(parts))
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token payload: %w", err)
+	}
+
+	var raw struct {
+		Username string `json:"username"`
+		Iat      int64  `json:"iat"`
+		Exp      int64  `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+
+	claims := &Claims{Username: raw.Username}
+	if raw.Iat != 0 {
+		claims.IssuedAt = time.Unix(raw.Iat, 0)
+	}
+	if raw.Exp != 0 {
+		claims.ExpiresAt = time.Unix(raw.Exp, 0)
+	}
+	return claims, nil
+}
+
+// Expires reports whether the token has an expiry date.
+func (c *Claims) Expires() bool {
+	return !c.ExpiresAt.IsZero()
+}
+
+// ExpiresIn returns the time left before the token expires, negative once
+// it has. It is meaningless if the token does not expire.
+func (c *Claims) ExpiresIn(now time.Time) time.Duration {
+	return c.ExpiresAt.Sub(now)
+}
+
+// Claims decodes the client's current token.
+func (c *Client) Claims() (*Claims, error) {
+	token := c.GetToken()
+	if token == "" {
+		return nil, fmt.Errorf("not logged in")
+	}
+	return ParseClaims(token)
+}
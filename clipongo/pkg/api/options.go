@@ -0,0 +1,101 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Option configures a Client at construction time.
+type Option func(*Client) error
+
+const (
+	defaultTimeout         = 15 * time.Second
+	defaultDialTimeout     = 5 * time.Second
+	defaultMaxIdleConns    = 16
+	defaultIdleConnTimeout = 90 * time.Second
+)
+
+type transportOptions struct {
+	timeout         time.Duration
+	dialTimeout     time.Duration
+	maxIdleConns    int
+	idleConnTimeout time.Duration
+	roundTripper    http.RoundTripper
+}
+
+func defaultTransportOptions() transportOptions {
+	return transportOptions{
+		timeout:         defaultTimeout,
+		dialTimeout:     defaultDialTimeout,
+		maxIdleConns:    defaultMaxIdleConns,
+		idleConnTimeout: defaultIdleConnTimeout,
+	}
+}
+
+// WithTimeout sets the default time limit of every HTTP call, including
+// reading the body. A shorter deadline on the call context still applies.
+// Zero disables the limit.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) error {
+		if d < 0 {
+			return fmt.Errorf("negative timeout %s", d)
+		}
+		c.transport.timeout = d
+		return nil
+	}
+}
+
+// WithDialTimeout bounds establishing the TCP connection, for both the REST
+// calls and the WebSocket.
+func WithDialTimeout(d time.Duration) Option {
+	return func(c *Client) error {
+		if d <= 0 {
+			return fmt.Errorf("dial timeout must be positive, got %s", d)
+		}
+		c.transport.dialTimeout = d
+		return nil
+	}
+}
+
+// WithMaxIdleConns sets how many keep-alive connections to the server are
+// kept open between calls.
+func WithMaxIdleConns(n int) Option {
+	return func(c *Client) error {
+		if n < 0 {
+			return fmt.Errorf("negative max idle conns %d", n)
+		}
+		c.transport.maxIdleConns = n
+		return nil
+	}
+}
+
+// WithIdleConnTimeout sets how long an idle keep-alive connection is kept.
+func WithIdleConnTimeout(d time.Duration) Option {
+	return func(c *Client) error {
+		c.transport.idleConnTimeout = d
+		return nil
+	}
+}
+
+// WithRoundTripper replaces the transport built by the client, e.g. to
+// record or fake requests. The TLS and connection pool options then only
+// apply to the WebSocket dialer.
+func WithRoundTripper(rt http.RoundTripper) Option {
+	return func(c *Client) error {
+		if rt == nil {
+			return fmt.Errorf("nil round tripper")
+		}
+		c.transport.roundTripper = rt
+		return nil
+	}
+}
+
+// WithTokenRefresh registers fn to be called with the new token whenever
+// the client logs in again after its token was rejected.
+func WithTokenRefresh(fn func(token string)) Option {
+	return func(c *Client) error {
+		c.onTokenRefresh = fn
+		return nil
+	}
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy describes how failed calls are retried. Only safe calls are
+// retried: GET requests, and other requests whose context carries an
+// idempotency key (see WithIdempotencyKey).
+type RetryPolicy struct {
+	// MaxAttempts counts the first attempt, so 1 disables retries.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry, doubled for each
+	// following one up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Jitter is the fraction of each delay that is randomized, between 0
+	// and 1, so that clients do not retry in lockstep.
+	Jitter float64
+	// RetryOn lists the HTTP statuses worth retrying. Transport errors,
+	// such as a reset connection or a timeout, are always retried; TLS
+	// and decoding errors never are.
+	RetryOn map[int]bool
+}
+
+// DefaultRetryPolicy retries up to twice on network errors and on the
+// statuses a proxy returns while the backend restarts.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   200 * time.Millisecond,
+		MaxDelay:    2 * time.Second,
+		Jitter:      0.5,
+		RetryOn: map[int]bool{
+			http.StatusTooManyRequests:    true,
+			http.StatusBadGateway:         true,
+			http.StatusServiceUnavailable: true,
+			http.StatusGatewayTimeout:     true,
+		},
+	}
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Client) error {
+		if p.MaxAttempts < 1 {
+			return fmt.Errorf("retry policy needs at least 1 attempt, got %d", p.MaxAttempts)
+		}
+		if p.Jitter < 0 || p.Jitter > 1 {
+			return fmt.Errorf("retry jitter must be between 0 and 1, got %v", p.Jitter)
+		}
+		c.retry = p
+		return nil
+	}
+}
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey returns a context that sends key in the
+// Idempotency-Key header, which makes non-GET calls such as CreateGame
+// eligible for retries. Use a new key for every logical operation.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+func idempotencyKeyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKey{}).(string)
+	return key
+}
+
+// retryable reports whether err, returned by an attempt, may succeed if
+// the call is sent again.
+func (p RetryPolicy) retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return p.RetryOn[apiErr.StatusCode]
+	}
+	// Certificate errors come back the same on every attempt, even when
+	// wrapped in a net.OpError by a TLS alert.
+	var certErr *CertificateChangedError
+	var verifyErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	var recordErr tls.RecordHeaderError
+	if errors.As(err, &certErr) || errors.As(err, &verifyErr) || errors.As(err, &authorityErr) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) || errors.As(err, &recordErr) {
+		return false
+	}
+	var opErr *net.OpError
+	var netErr net.Error
+	return errors.As(err, &opErr) ||
+		(errors.As(err, &netErr) && netErr.Timeout()) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
+
+// delay returns the wait before retry n, starting at 1.
+func (p RetryPolicy) delay(n int) time.Duration {
+	d := p.BaseDelay << (n - 1)
+	if d > p.MaxDelay || d <= 0 {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 && d > 0 {
+		d -= time.Duration(rand.Float64() * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,247 @@
+package apitest
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"clipongo/pkg/api"
+	"clipongo/pkg/realtime"
+
+	"github.com/gorilla/websocket"
+)
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/login", s.handleLogin)
+	mux.HandleFunc("GET /api/user", s.authenticated(s.handleUser))
+	mux.HandleFunc("POST /api/game", s.authenticated(s.handleCreateGame))
+	mux.HandleFunc("GET /api/user/games", s.authenticated(s.handleUserGames))
+	mux.HandleFunc("GET /api/game/{id}", s.authenticated(s.handleGetGame))
+	mux.HandleFunc("POST /api/game/{id}/pause", s.authenticated(s.handlePause(true)))
+	mux.HandleFunc("POST /api/game/{id}/unpause", s.authenticated(s.handlePause(false)))
+	mux.HandleFunc("GET /ws/game/{id}", s.handleSocket)
+	return s.record(mux)
+}
+
+// record logs every request and serves the failures set up with Fail.
+func (s *Server) record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+		status := 0
+		for i := range s.failures {
+			f := &s.failures[i]
+			if f.remaining > 0 && f.method == r.Method && f.path == r.URL.Path {
+				f.remaining--
+				status = f.status
+				break
+			}
+		}
+		s.mu.Unlock()
+
+		if status != 0 {
+			writeError(w, status, http.StatusText(status))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type userHandler func(w http.ResponseWriter, r *http.Request, username string)
+
+// authenticated rejects requests without a valid bearer token with the
+// body Fastify sends.
+func (s *Server) authenticated(next userHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		s.mu.Lock()
+		username, valid := s.user(token)
+		s.mu.Unlock()
+		if !ok || !valid {
+			writeJSON(w, http.StatusUnauthorized, map[string]any{
+				"statusCode": http.StatusUnauthorized,
+				"code":       "FST_JWT_AUTHORIZATION_TOKEN_INVALID",
+				"error":      "Unauthorized",
+				"message":    "Authorization token is invalid",
+			})
+			return
+		}
+		next(w, r, username)
+	}
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var body api.AuthRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "body must be JSON")
+		return
+	}
+	if n := len([]rune(body.Username)); n < minUsernameLen || n > maxUsernameLen {
+		writeError(w, http.StatusBadRequest, "body/username must be between 1 and 10 characters")
+		return
+	}
+	writeJSON(w, http.StatusOK, api.AuthResponse{Token: s.Login(body.Username)})
+}
+
+func (s *Server) handleUser(w http.ResponseWriter, r *http.Request, username string) {
+	writeJSON(w, http.StatusOK, api.User{Username: username})
+}
+
+func (s *Server) handleCreateGame(w http.ResponseWriter, r *http.Request, username string) {
+	var body struct {
+		Opponents []string `json:"opponents"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "body must be JSON")
+		return
+	}
+
+	players := []string{username, username}
+	if body.Opponents != nil {
+		players = append([]string{username}, body.Opponents...)
+		if len(players)%2 != 0 || len(players) > api.MaxPlayers {
+			// The backend answers 404 here.
+			writeError(w, http.StatusNotFound, "Invalid number of opponents")
+			return
+		}
+	}
+
+	s.mu.Lock()
+	g := s.createGame(players)
+	state := clone(g.state)
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, state)
+}
+
+func (s *Server) handleUserGames(w http.ResponseWriter, r *http.Request, username string) {
+	s.mu.Lock()
+	games := []api.GameSummary{}
+	for id, g := range s.games {
+		if g.state.PlayerIndex(username) >= 0 {
+			games = append(games, api.GameSummary{ID: id})
+		}
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, games)
+}
+
+func (s *Server) handleGetGame(w http.ResponseWriter, r *http.Request, username string) {
+	state, ok := s.Game(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+func (s *Server) handlePause(pause bool) userHandler {
+	return func(w http.ResponseWriter, r *http.Request, username string) {
+		s.mu.Lock()
+		g, ok := s.games[r.PathValue("id")]
+		var state api.GameState
+		if ok {
+			g.state.Pause = pause
+			state = clone(g.state)
+		}
+		s.mu.Unlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, "Game not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, state)
+	}
+}
+
+// handleSocket accepts the players of the game, like the backend: anyone
+// else is disconnected right after the upgrade. The game is unpaused once
+// every player joined.
+func (s *Server) handleSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	id := r.PathValue("id")
+	s.mu.Lock()
+	username, valid := s.user(r.URL.Query().Get("token"))
+	g, ok := s.games[id]
+	if !valid || !ok || g.state.PlayerIndex(username) < 0 {
+		s.mu.Unlock()
+		conn.Close()
+		return
+	}
+	g.sockets[username] = conn
+	conn.SetPingHandler(func(data string) error {
+		s.mu.Lock()
+		muted := g.muted[conn]
+		s.mu.Unlock()
+		if muted {
+			return nil
+		}
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	if len(g.sockets) == len(uniquePlayers(g.state)) {
+		g.state.Pause = false
+	}
+	msg, err := realtime.NewMessage(realtime.TypeGameState, g.state)
+	if err == nil {
+		err = conn.WriteJSON(msg)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	select {
+	case g.joined <- username:
+	default:
+	}
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			s.mu.Lock()
+			if g.sockets[username] == conn {
+				delete(g.sockets, username)
+			}
+			s.mu.Unlock()
+			return
+		}
+		var msg realtime.Message
+		var move realtime.PaddleMove
+		if err := json.Unmarshal(data, &msg); err != nil || msg.Type != realtime.TypePaddleMove {
+			log.Printf("apitest: ignoring message %s", data)
+			continue
+		}
+		if err := json.Unmarshal(msg.Payload, &move); err != nil {
+			log.Printf("apitest: ignoring message %s", data)
+			continue
+		}
+		s.mu.Lock()
+		g.moves = append(g.moves, move)
+		s.mu.Unlock()
+	}
+}
+
+// uniquePlayers returns the distinct usernames of state, as a solo game
+// lists the host twice.
+func uniquePlayers(state api.GameState) map[string]bool {
+	names := make(map[string]bool)
+	for _, p := range state.Players {
+		names[p.Player.Username] = true
+	}
+	return names
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, api.PongError{Error: msg})
+}
@@ -0,0 +1,358 @@
+// Package apitest provides an in-memory fake of the Pong backend, to test
+// api.Client and the game loop without the real server.
+//
+// The fake implements the same routes and JSON shapes as the backend, but
+// games only move when the test says so: frames are pushed to the
+// connected WebSockets with SendState, and the paddle moves the clients
+// send are recorded and returned by Moves.
+package apitest
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"clipongo/pkg/api"
+	"clipongo/pkg/realtime"
+
+	"github.com/gorilla/websocket"
+)
+
+// Username limits of the backend.
+const (
+	minUsernameLen = 1
+	maxUsernameLen = 10
+)
+
+// Server is a fake backend listening on a local address.
+type Server struct {
+	// URL is the base URL to give to api.NewClient.
+	URL string
+
+	srv      *httptest.Server
+	upgrader websocket.Upgrader
+
+	mu       sync.Mutex
+	tokenTTL time.Duration
+	tokens   map[string]session
+	games    map[string]*game
+	nextID   int
+	failures []failure
+	requests []string
+}
+
+type game struct {
+	state   api.GameState
+	sockets map[string]*websocket.Conn // username -> connection
+	muted   map[*websocket.Conn]bool
+	moves   []realtime.PaddleMove
+	joined  chan string
+}
+
+// session is what the server knows of an issued token.
+type session struct {
+	username string
+	expires  time.Time // zero for never
+}
+
+type failure struct {
+	method, path string
+	status       int
+	remaining    int
+}
+
+// NewServer starts a fake backend over plain HTTP. Close it when done.
+func NewServer() *Server {
+	s := newServer()
+	s.srv = httptest.NewServer(s.routes())
+	s.URL = s.srv.URL
+	return s
+}
+
+// NewTLSServer starts a fake backend over HTTPS, with a certificate that
+// Certificate returns.
+func NewTLSServer() *Server {
+	s := newServer()
+	s.srv = httptest.NewTLSServer(s.routes())
+	s.URL = s.srv.URL
+	return s
+}
+
+func newServer() *Server {
+	return &Server{
+		upgrader: websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }},
+		tokens:   make(map[string]session),
+		games:    make(map[string]*game),
+	}
+}
+
+// Close shuts the server down, closing every WebSocket.
+func (s *Server) Close() {
+	s.mu.Lock()
+	for _, g := range s.games {
+		for _, conn := range g.sockets {
+			conn.Close()
+		}
+	}
+	s.mu.Unlock()
+	s.srv.Close()
+}
+
+// Certificate returns the certificate of a server started with
+// NewTLSServer.
+func (s *Server) Certificate() *x509.Certificate {
+	return s.srv.Certificate()
+}
+
+// SetTokenTTL makes the tokens issued from now on expire after ttl, in
+// their claims and on the server. Zero, the default, means never.
+func (s *Server) SetTokenTTL(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokenTTL = ttl
+}
+
+// Login issues a token for username, like POST /api/login.
+func (s *Server) Login(username string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.issue(username)
+}
+
+// RevokeTokens makes every token issued so far rejected with 401, as when
+// they expire.
+func (s *Server) RevokeTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = make(map[string]session)
+}
+
+// Fail makes the next times calls to method and path answer status
+// instead of being served, e.g. to exercise retries.
+func (s *Server) Fail(method, path string, status, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, failure{method: method, path: path, status: status, remaining: times})
+}
+
+// Requests returns the "METHOD /path" of every request served so far.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// CreateGame adds a paused game between players, like POST /api/game does
+// for players[0] with the others as opponents.
+func (s *Server) CreateGame(players ...string) api.GameState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return clone(s.createGame(players).state)
+}
+
+// Game returns the current state of game id.
+func (s *Server) Game(id string) (api.GameState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.games[id]
+	if !ok {
+		return api.GameState{}, false
+	}
+	return clone(g.state), true
+}
+
+// EndGame removes game id, as the backend does once it is won.
+func (s *Server) EndGame(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if g, ok := s.games[id]; ok {
+		for _, conn := range g.sockets {
+			conn.Close()
+		}
+		delete(s.games, id)
+	}
+}
+
+// Disconnect drops the WebSocket of username in game id without ending
+// the game, as a flaky network would.
+func (s *Server) Disconnect(id, username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if g, ok := s.games[id]; ok {
+		if conn, ok := g.sockets[username]; ok {
+			conn.Close()
+			delete(g.sockets, username)
+		}
+	}
+}
+
+// Mute stops answering the pings of the current WebSocket of username in
+// game id, as a connection that silently died would.
+func (s *Server) Mute(id, username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if g, ok := s.games[id]; ok {
+		if conn, ok := g.sockets[username]; ok {
+			g.muted[conn] = true
+		}
+	}
+}
+
+// WaitJoined blocks until username opened the WebSocket of game id, or
+// fails after timeout.
+func (s *Server) WaitJoined(id, username string, timeout time.Duration) error {
+	s.mu.Lock()
+	g, ok := s.games[id]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("no game %s", id)
+	}
+	if _, ok := g.sockets[username]; ok {
+		s.mu.Unlock()
+		return nil
+	}
+	joined := g.joined
+	s.mu.Unlock()
+
+	deadline := time.After(timeout)
+	for {
+		select {
+		case name := <-joined:
+			if name == username {
+				return nil
+			}
+		case <-deadline:
+			return fmt.Errorf("%s did not join game %s within %s", username, id, timeout)
+		}
+	}
+}
+
+// SendState replaces the state of game id and sends it as a game_state
+// frame to every connected player.
+func (s *Server) SendState(id string, state api.GameState) error {
+	msg, err := realtime.NewMessage(realtime.TypeGameState, state)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.games[id]
+	if !ok {
+		return fmt.Errorf("no game %s", id)
+	}
+	g.state = clone(state)
+	return s.broadcast(g, msg)
+}
+
+// Send sends msg as is to every player connected to game id, e.g. to
+// exercise message types the client does not know.
+func (s *Server) Send(id string, msg realtime.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.games[id]
+	if !ok {
+		return fmt.Errorf("no game %s", id)
+	}
+	return s.broadcast(g, msg)
+}
+
+// Moves returns the paddle_move payloads received for game id.
+func (s *Server) Moves(id string) []realtime.PaddleMove {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.games[id]
+	if !ok {
+		return nil
+	}
+	return append([]realtime.PaddleMove(nil), g.moves...)
+}
+
+// issue must be called with s.mu held.
+func (s *Server) issue(username string) string {
+	claims := map[string]any{"username": username, "iat": time.Now().Unix()}
+	var expires time.Time
+	if s.tokenTTL > 0 {
+		expires = time.Now().Add(s.tokenTTL)
+		claims["exp"] = expires.Unix()
+	}
+	payload, _ := json.Marshal(claims)
+	// The signature is never checked by the client, only its uniqueness
+	// matters here.
+	s.nextID++
+	token := strings.Join([]string{
+		base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)),
+		base64.RawURLEncoding.EncodeToString(payload),
+		fmt.Sprintf("fake%d", s.nextID),
+	}, ".")
+	s.tokens[token] = session{username: username, expires: expires}
+	return token
+}
+
+// user returns the username token was issued to, unless it is unknown or
+// expired. It must be called with s.mu held.
+func (s *Server) user(token string) (string, bool) {
+	t, ok := s.tokens[token]
+	if !ok || (!t.expires.IsZero() && !time.Now().Before(t.expires)) {
+		return "", false
+	}
+	return t.username, true
+}
+
+// clone returns a copy of state that shares no memory with it, so that
+// tests may change the states they pass or get back without holding s.mu.
+func clone(state api.GameState) api.GameState {
+	state.Players = slices.Clone(state.Players)
+	return state
+}
+
+// createGame must be called with s.mu held. It lays out the paddles like
+// the backend does.
+func (s *Server) createGame(players []string) *game {
+	s.nextID++
+	id := fmt.Sprintf("00000000-0000-4000-8000-%012d", s.nextID)
+
+	state := api.GameState{
+		ID:    id,
+		Pause: true,
+		Ball:  api.Ball{X: 500, Y: 250, Vx: -5, Vy: 0},
+	}
+	for i, name := range players {
+		y := 200.0
+		if len(players) > 2 {
+			y = 75
+			if i >= 2 {
+				y = 325
+			}
+		}
+		state.Players = append(state.Players, api.GamePlayer{
+			Player: api.Player{Username: name},
+			Paddle: api.Paddle{Y: y},
+		})
+	}
+
+	g := &game{
+		state:   state,
+		sockets: make(map[string]*websocket.Conn),
+		muted:   make(map[*websocket.Conn]bool),
+		joined:  make(chan string, 8),
+	}
+	s.games[id] = g
+	return g
+}
+
+// broadcast must be called with s.mu held.
+func (s *Server) broadcast(g *game, msg realtime.Message) error {
+	for name, conn := range g.sockets {
+		if err := conn.WriteJSON(msg); err != nil {
+			return fmt.Errorf("failed to send %s to %s: %w", msg.Type, name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+// Package config resolves the CLI settings from the config file, the
+// environment and command line flags.
+package config
+
+import (
+	"clipongo/pkg/api"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// DefaultServer is the ingress started by docker compose.
+	DefaultServer = "https://localhost:1443"
+
+	// EnvServer overrides the server from the config file.
+	EnvServer = "CLIPONGO_SERVER"
+	// EnvConfig overrides the location of the config file.
+	EnvConfig = "CLIPONGO_CONFIG"
+	// EnvCAFile overrides the CA bundle from the config file.
+	EnvCAFile = "CLIPONGO_CA_FILE"
+)
+
+type Config struct {
+	// Server is the base URL of the API, e.g. https://localhost:1443.
+	// The WebSocket endpoint is derived from it.
+	Server string `json:"server"`
+
+	// CAFile is a PEM bundle trusted in addition to the system roots.
+	CAFile string `json:"ca_file"`
+	// TrustOnFirstUse pins the server certificate in KnownHosts the first
+	// time it is seen instead of verifying its chain.
+	TrustOnFirstUse bool `json:"tofu"`
+	// KnownHosts defaults to known_hosts in Dir.
+	KnownHosts string `json:"known_hosts"`
+	// Insecure disables certificate verification. It can only be set
+	// with a flag, never persisted.
+	Insecure bool `json:"-"`
+
+	// ReconnectTimeout is how long a game keeps reconnecting a lost
+	// connection before giving up, e.g. "45s". Zero keeps the default.
+	ReconnectTimeout Duration `json:"reconnect_timeout,omitempty"`
+	// HUD shows the ping, jitter and frame rate during games.
+	HUD bool `json:"hud"`
+	// RawBall disables the prediction of the ball between frames.
+	RawBall bool `json:"raw_ball"`
+	// Mouse makes the paddle follow the mouse pointer.
+	Mouse bool `json:"mouse"`
+	// PollInterval is how often a spectated game is fetched when the
+	// WebSocket refuses non-players. Zero keeps the default.
+	PollInterval Duration `json:"poll_interval,omitempty"`
+
+	// KeyRepeatDelay and KeyRepeatInterval describe the auto-repeat of
+	// the terminal, as measured by the calibrate command. Zero keeps the
+	// defaults.
+	KeyRepeatDelay    Duration `json:"key_repeat_delay,omitempty"`
+	KeyRepeatInterval Duration `json:"key_repeat_interval,omitempty"`
+
+	// Keymap is the key bindings file, keys.json in Dir by default.
+	Keymap string `json:"keymap,omitempty"`
+}
+
+// Duration is a time.Duration written like "30s", both in the config file
+// and on the command line.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Set implements flag.Value.
+func (d *Duration) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %w", err)
+	}
+	return d.Set(s)
+}
+
+// Dir returns the clipongo directory inside the user's config dir
+// ($XDG_CONFIG_HOME/clipongo on Linux).
+func Dir() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config dir: %w", err)
+	}
+	return filepath.Join(base, "clipongo"), nil
+}
+
+// Path returns the config file to use: $CLIPONGO_CONFIG if set, otherwise
+// config.json in Dir.
+func Path() (string, error) {
+	if p := os.Getenv(EnvConfig); p != "" {
+		return p, nil
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.json"), nil
+}
+
+// Load reads the config file at path. A missing file is not an error and
+// yields the defaults.
+func Load(path string) (*Config, error) {
+	cfg := &Config{Server: DefaultServer}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Save writes cfg to the config file at path, creating its directory.
+func Save(path string, cfg *Config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create config dir: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// Resolve loads the config file at path (or the default one when path is
+// empty), then applies the environment and finally the non-zero fields of
+// flags, so flags win over the environment which wins over the file.
+func Resolve(path string, flags Config) (*Config, error) {
+	if path == "" {
+		p, err := Path()
+		if err != nil {
+			return nil, err
+		}
+		path = p
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg.merge(fromEnv())
+	cfg.merge(flags)
+
+	if cfg.TrustOnFirstUse && cfg.KnownHosts == "" {
+		dir, err := Dir()
+		if err != nil {
+			return nil, err
+		}
+		cfg.KnownHosts = filepath.Join(dir, "known_hosts")
+	}
+	if cfg.Keymap == "" {
+		dir, err := Dir()
+		if err != nil {
+			return nil, err
+		}
+		cfg.Keymap = filepath.Join(dir, "keys.json")
+	}
+	return cfg, nil
+}
+
+func fromEnv() Config {
+	return Config{
+		Server: os.Getenv(EnvServer),
+		CAFile: os.Getenv(EnvCAFile),
+	}
+}
+
+func (c *Config) merge(o Config) {
+	if o.Server != "" {
+		c.Server = o.Server
+	}
+	if o.CAFile != "" {
+		c.CAFile = o.CAFile
+	}
+	if o.TrustOnFirstUse {
+		c.TrustOnFirstUse = true
+	}
+	if o.KnownHosts != "" {
+		c.KnownHosts = o.KnownHosts
+	}
+	if o.Insecure {
+		c.Insecure = true
+	}
+	if o.ReconnectTimeout != 0 {
+		c.ReconnectTimeout = o.ReconnectTimeout
+	}
+	if o.HUD {
+		c.HUD = true
+	}
+	if o.RawBall {
+		c.RawBall = true
+	}
+	if o.Mouse {
+		c.Mouse = true
+	}
+	if o.PollInterval != 0 {
+		c.PollInterval = o.PollInterval
+	}
+	if o.KeyRepeatDelay != 0 {
+		c.KeyRepeatDelay = o.KeyRepeatDelay
+	}
+	if o.KeyRepeatInterval != 0 {
+		c.KeyRepeatInterval = o.KeyRepeatInterval
+	}
+	if o.Keymap != "" {
+		c.Keymap = o.Keymap
+	}
+}
+
+// ClientOptions translates the TLS settings into api.Client options.
+func (c *Config) ClientOptions(onFirstUse func(host, fingerprint string)) []api.Option {
+	var opts []api.Option
+	if c.CAFile != "" {
+		opts = append(opts, api.WithCAFile(c.CAFile))
+	}
+	if c.KnownHosts != "" {
+		opts = append(opts, api.WithKnownHosts(c.KnownHosts, onFirstUse))
+	}
+	if c.Insecure {
+		opts = append(opts, api.WithInsecureSkipVerify())
+	}
+	return opts
+}
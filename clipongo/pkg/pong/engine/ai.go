@@ -0,0 +1,196 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"clipongo/pkg/api"
+	"clipongo/pkg/realtime"
+)
+
+// Skill is how well the AI plays.
+type Skill struct {
+	// ReactionDelay is how long the AI takes to notice that the ball
+	// changed direction.
+	ReactionDelay time.Duration
+	// Speed is the share of the ticks the paddle moves on, up to 1.
+	Speed float64
+	// Error is how far off, at most, the AI guesses where the ball will
+	// be, in game units.
+	Error float64
+}
+
+// Difficulty is a named Skill.
+type Difficulty struct {
+	Name string
+	Skill
+}
+
+// Difficulties are the levels offered to players, from the easiest.
+var Difficulties = []Difficulty{
+	{"easy", Skill{ReactionDelay: 300 * time.Millisecond, Speed: 0.5, Error: 60}},
+	{"medium", Skill{ReactionDelay: 150 * time.Millisecond, Speed: 0.75, Error: 35}},
+	{"hard", Skill{ReactionDelay: 50 * time.Millisecond, Speed: 1, Error: 15}},
+}
+
+// LookupDifficulty returns the skill of the difficulty called name.
+func LookupDifficulty(name string) (Skill, error) {
+	var names []string
+	for _, d := range Difficulties {
+		if d.Name == name {
+			return d.Skill, nil
+		}
+		names = append(names, d.Name)
+	}
+	return Skill{}, fmt.Errorf("unknown difficulty %q (want one of %s)", name, strings.Join(names, ", "))
+}
+
+// AI is a Controller that steers its paddle to where the ball will cross
+// it, and back to the center when the ball goes away.
+type AI struct {
+	paddle int
+	skill  Skill
+	rng    *rand.Rand
+
+	tick    int
+	lastX   float64
+	lastVx  float64
+	planAt  int // tick to plan the next target at, -1 once done
+	target  float64
+	planned bool
+	budget  float64 // ticks the paddle may move on, from Speed
+	burst   int     // ticks saved up before the paddle starts moving
+	moving  realtime.Direction
+}
+
+// NewAI returns an AI for paddle, which misses from seed.
+func NewAI(paddle int, skill Skill, seed uint64) *AI {
+	return &AI{
+		paddle: paddle,
+		skill:  skill,
+		rng:    rand.New(rand.NewPCG(seed, seed+1)),
+		burst:  1,
+	}
+}
+
+// SetBurst makes a slow AI move for ticks ticks in a row, then rest for as
+// long as its Speed requires, instead of moving every other tick. A paddle
+// played over the network then gets a couple of moves a second rather
+// than one per frame. The first burst is ready at once.
+func (a *AI) SetBurst(ticks int) {
+	a.burst = max(ticks, 1)
+	a.budget = float64(a.burst)
+}
+
+// Next implements Controller. It can also be fed the states received from
+// a server, one per tick.
+func (a *AI) Next(state *api.GameState) []realtime.PaddleMove {
+	if a.paddle >= len(state.Players) {
+		return nil
+	}
+	ball := state.Ball
+	// A serve after a point may go the same way as the ball that scored,
+	// but it starts back from the center.
+	served := math.Abs(ball.X-a.lastX) > GameWidth/4
+	if math.Signbit(ball.Vx) != math.Signbit(a.lastVx) || a.lastVx == 0 || served {
+		// A hit or a serve: look again once the AI noticed.
+		a.planAt = a.tick + int(a.skill.ReactionDelay/Tick)
+	}
+	a.lastX = ball.X
+	a.lastVx = ball.Vx
+	if a.planAt >= 0 && a.tick >= a.planAt {
+		a.plan(ball)
+		a.planAt = -1
+	}
+	a.tick++
+	if !a.planned {
+		return nil
+	}
+
+	y := state.Players[a.paddle].Paddle.Y
+	var want realtime.Direction
+	switch diff := a.target - y; {
+	case diff > PaddleSpeed/2:
+		want = realtime.Down
+	case diff < -PaddleSpeed/2:
+		want = realtime.Up
+	}
+	a.budget = min(a.budget+a.skill.Speed, float64(a.burst))
+	if want != "" {
+		// A resting paddle waits for a whole burst.
+		need := 1.0
+		if a.moving == "" {
+			need = float64(a.burst)
+		}
+		if a.budget < need {
+			want = ""
+		} else {
+			a.budget--
+		}
+	}
+	return a.steer(want)
+}
+
+// plan picks the paddle top to go to.
+func (a *AI) plan(ball api.Ball) {
+	a.planned = true
+	y, _, ok := Intercept(ball, contactX(api.Team(a.paddle)))
+	if !ok {
+		a.target = GameHeight/2 - PaddleHeight/2
+		return
+	}
+	miss := (a.rng.Float64()*2 - 1) * a.skill.Error
+	a.target = min(max(y+miss-PaddleHeight/2, 0), GameHeight-PaddleHeight)
+}
+
+func (a *AI) steer(want realtime.Direction) []realtime.PaddleMove {
+	if want == a.moving {
+		return nil
+	}
+	var moves []realtime.PaddleMove
+	if a.moving != "" {
+		moves = append(moves, realtime.PaddleMove{Paddle: a.paddle, Direction: a.moving, Moving: false})
+	}
+	if want != "" {
+		moves = append(moves, realtime.PaddleMove{Paddle: a.paddle, Direction: want, Moving: true})
+	}
+	a.moving = want
+	return moves
+}
+
+// contactX is the x of the center of the ball when it touches the
+// paddles of team.
+func contactX(team int) float64 {
+	if team == 0 {
+		return PaddleWidth + BallSize/2
+	}
+	return GameWidth - PaddleWidth - BallSize/2
+}
+
+// Intercept returns the y at which the ball will cross x, bouncing on the
+// top and bottom walls, and how many ticks it takes. ok is false when the
+// ball does not go toward x.
+func Intercept(ball api.Ball, x float64) (y float64, ticks float64, ok bool) {
+	dx := x - ball.X
+	if ball.Vx == 0 || math.Signbit(dx) != math.Signbit(ball.Vx) {
+		return 0, 0, false
+	}
+	ticks = dx / ball.Vx
+	return fold(ball.Y+ball.Vy*ticks, BallSize/2, GameHeight-BallSize/2), ticks, true
+}
+
+// fold reflects y into [lo, hi] as if it bounced on both bounds.
+func fold(y, lo, hi float64) float64 {
+	span := hi - lo
+	m := math.Mod(y-lo, 2*span)
+	if m < 0 {
+		m += 2 * span
+	}
+	if m > span {
+		m = 2*span - m
+	}
+	return lo + m
+}
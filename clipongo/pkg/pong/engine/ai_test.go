@@ -0,0 +1,159 @@
+package engine
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"clipongo/pkg/api"
+	"clipongo/pkg/realtime"
+)
+
+func TestIntercept(t *testing.T) {
+	tests := []struct {
+		name      string
+		ball      api.Ball
+		x         float64
+		wantY     float64
+		wantTicks float64
+		wantOK    bool
+	}{
+		{"straight", api.Ball{X: 500, Y: 250, Vx: 5}, 985, 250, 97, true},
+		{"diagonal", api.Ball{X: 500, Y: 100, Vx: 5, Vy: 1}, 600, 120, 20, true},
+		{"off the bottom wall", api.Ball{X: 500, Y: 450, Vx: 5, Vy: 5}, 600, 440, 20, true},
+		{"off both walls", api.Ball{X: 100, Y: 250, Vx: -1, Vy: 10}, 10, 170, 90, true},
+		{"going away", api.Ball{X: 500, Y: 250, Vx: -5}, 985, 0, 0, false},
+		{"still", api.Ball{X: 500, Y: 250}, 985, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			y, ticks, ok := Intercept(tt.ball, tt.x)
+			if ok != tt.wantOK || !near(y, tt.wantY) || !near(ticks, tt.wantTicks) {
+				t.Errorf("Intercept = (%v, %v, %v), want (%v, %v, %v)", y, ticks, ok, tt.wantY, tt.wantTicks, tt.wantOK)
+			}
+		})
+	}
+}
+
+// aiMoves feeds ai the state of a ball going toward its right paddle, at
+// paddleY, for ticks ticks, and returns what it sent.
+func aiMoves(ai *AI, ball api.Ball, paddleY float64, ticks int) []realtime.PaddleMove {
+	state := &api.GameState{
+		Players: []api.GamePlayer{{}, {Paddle: api.Paddle{Y: paddleY}}},
+		Ball:    ball,
+	}
+	var moves []realtime.PaddleMove
+	for range ticks {
+		moves = append(moves, ai.Next(state)...)
+	}
+	return moves
+}
+
+func TestAISkill(t *testing.T) {
+	ball := api.Ball{X: 500, Y: 400, Vx: 5}
+	down := realtime.PaddleMove{Paddle: 1, Direction: realtime.Down, Moving: true}
+	stop := realtime.PaddleMove{Paddle: 1, Direction: realtime.Down, Moving: false}
+	tests := []struct {
+		name  string
+		skill Skill
+		ticks int
+		want  []realtime.PaddleMove
+	}{
+		{"reacts at once", Skill{Speed: 1}, 3, []realtime.PaddleMove{down}},
+		{"waits for the reaction delay", Skill{ReactionDelay: 3 * Tick, Speed: 1}, 3, nil},
+		{"then moves", Skill{ReactionDelay: 3 * Tick, Speed: 1}, 4, []realtime.PaddleMove{down}},
+		{"rests at half speed", Skill{Speed: 0.5}, 4, []realtime.PaddleMove{down, stop, down}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The paddle stays at the top: the AI keeps wanting to go down.
+			got := aiMoves(NewAI(1, tt.skill, 1), ball, 0, tt.ticks)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("moves = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAIBursts(t *testing.T) {
+	ball := api.Ball{X: 500, Y: 400, Vx: 5}
+	down := realtime.PaddleMove{Paddle: 1, Direction: realtime.Down, Moving: true}
+	stop := realtime.PaddleMove{Paddle: 1, Direction: realtime.Down, Moving: false}
+	ai := NewAI(1, Skill{Speed: 0.5}, 1)
+	ai.SetBurst(4)
+	// Moves for 7 ticks, then rests for 7: half speed with a move every 7
+	// ticks instead of every tick.
+	var got []int
+	for tick := range 40 {
+		if moves := aiMoves(ai, ball, 0, 1); len(moves) > 0 {
+			if moves[0] != down && moves[0] != stop {
+				t.Fatalf("tick %d: unexpected move %+v", tick, moves[0])
+			}
+			got = append(got, tick)
+		}
+	}
+	want := []int{0, 7, 14, 21, 28, 35}
+	if !slices.Equal(got, want) {
+		t.Errorf("moves sent on ticks %v, want %v", got, want)
+	}
+}
+
+func TestAIStopsInFrontOfTheBall(t *testing.T) {
+	ai := NewAI(1, Skill{Speed: 1}, 1)
+	if moves := aiMoves(ai, api.Ball{X: 500, Y: 250, Vx: 5}, 200, 5); len(moves) != 0 {
+		t.Errorf("AI already in front of the ball moved: %+v", moves)
+	}
+}
+
+func TestAIReturnsServes(t *testing.T) {
+	for _, d := range Difficulties {
+		t.Run(d.Name, func(t *testing.T) {
+			// With perfect aim, the speed and the reaction delay of every
+			// level are enough for the first rallies.
+			skill := d.Skill
+			skill.Error = 0
+			for seed := range uint64(20) {
+				g := New("test", []string{"wall", "ai"}, seed)
+				g.SetWall(0)
+				g.Control(1, NewAI(1, skill, seed))
+				g.SetPaused(false)
+				g.Advance(5 * time.Second)
+				if score := g.State().Players[0].Player.Score; score != 0 {
+					t.Errorf("seed %d: the AI missed %d balls", seed, score)
+				}
+			}
+		})
+	}
+}
+
+func TestAIPlaysAfterAMiss(t *testing.T) {
+	skill, err := LookupDifficulty("easy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	skill.Error = 40
+	for seed := range uint64(5) {
+		g := New("test", []string{"wall", "ai"}, seed)
+		g.SetWall(0)
+		ai := NewAI(1, skill, seed)
+		g.Control(1, ai)
+		g.SetPaused(false)
+		for i := 0; g.State().Players[0].Player.Score == 0; i++ {
+			if i == 100000 {
+				t.Fatalf("seed %d: the AI never missed", seed)
+			}
+			g.Step()
+		}
+		// The serve goes back to the AI, which must aim at it once it
+		// noticed.
+		serve := g.State().Ball
+		for range int(skill.ReactionDelay/Tick) + 2 {
+			g.Step()
+		}
+		y, _, _ := Intercept(serve, contactX(1))
+		want := min(max(y-PaddleHeight/2, 0), GameHeight-PaddleHeight)
+		if diff := ai.target - want; diff < -skill.Error || diff > skill.Error {
+			t.Errorf("seed %d: AI aims at %v after the serve, want %v ± %v", seed, ai.target, want, skill.Error)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+// Package engine plays Pong locally with the rules of the backend: the
+// same field, speeds and bounces, advanced one fixed tick at a time so
+// that a game is reproducible from its seed and its moves. Only the end
+// differs: local games are won at WinScore, where the backend plays to
+// 10.
+package engine
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"clipongo/pkg/api"
+	"clipongo/pkg/realtime"
+)
+
+const (
+	// WinScore ends the game.
+	WinScore = 3
+
+	// Game dimensions
+	GameWidth    = 1000 // Width of the game area
+	GameHeight   = 500  // Height of the game area
+	PaddleWidth  = 10   // Width of the paddles
+	PaddleHeight = 100  // Height of the paddles
+	BallSize     = 10   // Diameter of the ball
+
+	// Tick is the period of the game loop, the unit of the speeds.
+	Tick = 16 * time.Millisecond
+	// PaddleSpeed is how far a moving paddle goes each tick.
+	PaddleSpeed = 8
+	// ServeSpeed is the speed of the ball after a point.
+	ServeSpeed = 5
+	// SpeedUp multiplies the speed of the ball on each paddle hit.
+	SpeedUp = 1.2
+	// MaxServeAngle and MaxBounceAngle bound the angle of the ball with
+	// the horizontal, after a serve and after a hit on a paddle edge.
+	MaxServeAngle  = math.Pi / 6
+	MaxBounceAngle = math.Pi / 3
+)
+
+// Controller plays a paddle from inside the game loop, like the AI.
+type Controller interface {
+	// Next is called on each tick with the state before the tick, and
+	// returns the moves of the paddle.
+	Next(state *api.GameState) []realtime.PaddleMove
+}
+
+// Game is a game in progress. It starts paused, like on the backend until
+// every player joined.
+type Game struct {
+	state       api.GameState
+	moving      []realtime.PaddleMove
+	controllers map[int]Controller
+	walls       map[int]bool
+	rng         *rand.Rand
+	over        bool
+	// acc is the time Advance has yet to turn into ticks.
+	acc time.Duration
+}
+
+// New starts a game between players, the left team being players 0 and 2
+// and the right one players 1 and 3. The serves are drawn from seed.
+func New(id string, players []string, seed uint64) *Game {
+	g := &Game{
+		state:       api.GameState{ID: id, Pause: true},
+		moving:      make([]realtime.PaddleMove, len(players)),
+		controllers: make(map[int]Controller),
+		walls:       make(map[int]bool),
+		rng:         rand.New(rand.NewPCG(seed, seed)),
+	}
+	for i, name := range players {
+		y := GameHeight/2 - PaddleHeight/2
+		if len(players) > 2 {
+			y = GameHeight/4 - PaddleHeight/2
+			if i >= 2 {
+				y = GameHeight*3/4 - PaddleHeight/2
+			}
+		}
+		g.state.Players = append(g.state.Players, api.GamePlayer{
+			Player: api.Player{Username: name},
+			Paddle: api.Paddle{Y: float64(y)},
+		})
+	}
+	g.serve(-1)
+	return g
+}
+
+// State returns a copy of the current state.
+func (g *Game) State() *api.GameState {
+	state := g.state
+	state.Players = append([]api.GamePlayer(nil), g.state.Players...)
+	return &state
+}
+
+// SetPaused pauses or resumes the game.
+func (g *Game) SetPaused(paused bool) {
+	g.state.Pause = paused
+}
+
+// Over tells if a team reached WinScore.
+func (g *Game) Over() bool {
+	return g.over
+}
+
+// Move starts or stops a paddle, like a paddle_move message.
+func (g *Game) Move(move realtime.PaddleMove) error {
+	if move.Paddle < 0 || move.Paddle >= len(g.moving) {
+		return fmt.Errorf("no paddle %d in a game of %d players", move.Paddle, len(g.moving))
+	}
+	if move.Direction != realtime.Up && move.Direction != realtime.Down {
+		return fmt.Errorf("invalid direction %q", move.Direction)
+	}
+	g.moving[move.Paddle] = move
+	return nil
+}
+
+// Control hands paddle to c, which moves it on each tick.
+func (g *Game) Control(paddle int, c Controller) {
+	g.controllers[paddle] = c
+}
+
+// SetWall makes paddle a perfect wall, for practice: it stays in front of
+// the ball and never misses.
+func (g *Game) SetWall(paddle int) {
+	g.walls[paddle] = true
+}
+
+// Advance runs the ticks that fit in elapsed, keeping the rest for the
+// next call, and returns how many ran.
+func (g *Game) Advance(elapsed time.Duration) int {
+	g.acc += elapsed
+	n := 0
+	for g.acc >= Tick {
+		g.acc -= Tick
+		g.Step()
+		n++
+	}
+	return n
+}
+
+// Step runs one tick: the paddles move, then the ball, which bounces on
+// the walls and paddles or scores.
+func (g *Game) Step() {
+	if g.state.Pause || g.over {
+		return
+	}
+	for paddle, c := range g.controllers {
+		for _, move := range c.Next(g.State()) {
+			move.Paddle = paddle
+			g.Move(move)
+		}
+	}
+	for i, move := range g.moving {
+		if !move.Moving {
+			continue
+		}
+		paddle := &g.state.Players[i].Paddle
+		if move.Direction == realtime.Down {
+			paddle.Y = min(paddle.Y+PaddleSpeed, GameHeight-PaddleHeight)
+		} else {
+			paddle.Y = max(paddle.Y-PaddleSpeed, 0)
+		}
+	}
+
+	ball := &g.state.Ball
+	ball.X += ball.Vx
+	ball.Y += ball.Vy
+	for i := range g.walls {
+		g.state.Players[i].Paddle.Y = min(max(ball.Y-PaddleHeight/2, 0), GameHeight-PaddleHeight)
+	}
+
+	const r = BallSize / 2
+	hit := false
+	// Like the backend, the walls send the ball away from them and paddles
+	// are only hit away from the walls.
+	switch {
+	case ball.Y-r <= 0:
+		ball.Vy = math.Abs(ball.Vy)
+	case ball.Y+r >= GameHeight:
+		ball.Vy = -math.Abs(ball.Vy)
+	default:
+		for i, p := range g.state.Players {
+			if ball.Y+r < p.Paddle.Y || ball.Y-r > p.Paddle.Y+PaddleHeight {
+				continue
+			}
+			if api.Team(i) == 0 && ball.X-r <= PaddleWidth {
+				bounce(ball, p.Paddle.Y, 1)
+				hit = true
+			} else if api.Team(i) == 1 && ball.X+r >= GameWidth-PaddleWidth {
+				bounce(ball, p.Paddle.Y, -1)
+				hit = true
+			}
+		}
+	}
+	switch {
+	case hit:
+	case ball.X-r <= 0:
+		g.score(1)
+		g.serve(-1)
+	case ball.X+r >= GameWidth:
+		g.score(0)
+		g.serve(1)
+	}
+}
+
+// bounce sends the ball back toward direction, faster, and the steeper
+// the further from the center of the paddle at paddleY it hit.
+func bounce(ball *api.Ball, paddleY float64, direction float64) {
+	center := paddleY + PaddleHeight/2
+	normalized := (ball.Y - center) / (PaddleHeight / 2)
+	speed := math.Hypot(ball.Vx, ball.Vy) * SpeedUp
+	angle := normalized * MaxBounceAngle
+	ball.Vx = speed * math.Cos(angle) * direction
+	ball.Vy = speed * math.Sin(angle)
+}
+
+// score gives a point to team, whose score is kept on its first player,
+// and ends the game at WinScore.
+func (g *Game) score(team int) {
+	p := &g.state.Players[team].Player
+	p.Score++
+	if p.Score >= WinScore {
+		p.Won = true
+		g.over = true
+	}
+}
+
+// serve puts the ball back in the center, going toward direction.
+func (g *Game) serve(direction float64) {
+	angle := g.rng.Float64()*2*MaxServeAngle - MaxServeAngle
+	g.state.Ball = api.Ball{
+		X:  GameWidth / 2,
+		Y:  GameHeight / 2,
+		Vx: direction * ServeSpeed * math.Cos(angle),
+		Vy: ServeSpeed * math.Sin(angle),
+	}
+}
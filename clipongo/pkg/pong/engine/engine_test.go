@@ -0,0 +1,284 @@
+package engine
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"clipongo/pkg/api"
+	"clipongo/pkg/realtime"
+)
+
+const epsilon = 1e-9
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < epsilon
+}
+
+// running returns a running 1 vs 1 game with the ball and the paddle tops
+// given.
+func running(ball api.Ball, left, right float64) *Game {
+	g := New("test", []string{"alice", "bob"}, 1)
+	g.SetPaused(false)
+	g.state.Ball = ball
+	g.state.Players[0].Paddle.Y = left
+	g.state.Players[1].Paddle.Y = right
+	return g
+}
+
+func TestStepBounces(t *testing.T) {
+	tests := []struct {
+		name   string
+		ball   api.Ball
+		left   float64
+		right  float64
+		want   api.Ball
+		scores [2]int
+	}{
+		{
+			name: "moves",
+			ball: api.Ball{X: 500, Y: 250, Vx: 3, Vy: -4},
+			left: 200, right: 200,
+			want: api.Ball{X: 503, Y: 246, Vx: 3, Vy: -4},
+		},
+		{
+			name: "top wall",
+			ball: api.Ball{X: 500, Y: 8, Vx: 3, Vy: -4},
+			left: 200, right: 200,
+			want: api.Ball{X: 503, Y: 4, Vx: 3, Vy: 4},
+		},
+		{
+			name: "bottom wall",
+			ball: api.Ball{X: 500, Y: 492, Vx: -3, Vy: 4},
+			left: 200, right: 200,
+			want: api.Ball{X: 497, Y: 496, Vx: -3, Vy: -4},
+		},
+		{
+			name: "inside a wall moves away from it",
+			ball: api.Ball{X: 500, Y: 3, Vx: 0, Vy: 1},
+			left: 200, right: 200,
+			want: api.Ball{X: 500, Y: 4, Vx: 0, Vy: 1},
+		},
+		{
+			name: "center of the left paddle",
+			ball: api.Ball{X: 20, Y: 250, Vx: -5, Vy: 0},
+			left: 200, right: 200,
+			want: api.Ball{X: 15, Y: 250, Vx: 5 * SpeedUp, Vy: 0},
+		},
+		{
+			name: "center of the right paddle",
+			ball: api.Ball{X: 980, Y: 250, Vx: 5, Vy: 0},
+			left: 200, right: 200,
+			want: api.Ball{X: 985, Y: 250, Vx: -5 * SpeedUp, Vy: 0},
+		},
+		{
+			name: "bottom edge of the left paddle",
+			ball: api.Ball{X: 20, Y: 300, Vx: -5, Vy: 0},
+			left: 200, right: 200,
+			want: api.Ball{
+				X: 15, Y: 300,
+				Vx: 5 * SpeedUp * math.Cos(MaxBounceAngle),
+				Vy: 5 * SpeedUp * math.Sin(MaxBounceAngle),
+			},
+		},
+		{
+			name: "top edge of the right paddle",
+			ball: api.Ball{X: 980, Y: 200, Vx: 5, Vy: 0},
+			left: 200, right: 200,
+			want: api.Ball{
+				X: 985, Y: 200,
+				Vx: -5 * SpeedUp * math.Cos(MaxBounceAngle),
+				Vy: -5 * SpeedUp * math.Sin(MaxBounceAngle),
+			},
+		},
+		{
+			name: "grazes the paddle corner",
+			ball: api.Ball{X: 20, Y: 196, Vx: -5, Vy: 0},
+			left: 200, right: 200,
+			want: api.Ball{
+				X: 15, Y: 196,
+				Vx: 5 * SpeedUp * math.Cos(-54.0/50*MaxBounceAngle),
+				Vy: 5 * SpeedUp * math.Sin(-54.0/50*MaxBounceAngle),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := running(tt.ball, tt.left, tt.right)
+			g.Step()
+			got := g.State()
+			b := got.Ball
+			if !near(b.X, tt.want.X) || !near(b.Y, tt.want.Y) || !near(b.Vx, tt.want.Vx) || !near(b.Vy, tt.want.Vy) {
+				t.Errorf("ball = %+v, want %+v", b, tt.want)
+			}
+			if s := [2]int{got.Players[0].Player.Score, got.Players[1].Player.Score}; s != tt.scores {
+				t.Errorf("scores = %v, want %v", s, tt.scores)
+			}
+		})
+	}
+}
+
+func TestStepScores(t *testing.T) {
+	tests := []struct {
+		name   string
+		ball   api.Ball
+		scores [2]int
+		// serve is the sign of the velocity of the serve.
+		serve float64
+	}{
+		{"past the left paddle", api.Ball{X: 8, Y: 100, Vx: -5}, [2]int{0, 1}, -1},
+		{"past the right paddle", api.Ball{X: 992, Y: 100, Vx: 5}, [2]int{1, 0}, 1},
+		{"into a corner", api.Ball{X: 8, Y: 6, Vx: -5, Vy: -4}, [2]int{0, 1}, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The paddles are at the bottom, far from the ball.
+			g := running(tt.ball, GameHeight-PaddleHeight, GameHeight-PaddleHeight)
+			g.Step()
+			got := g.State()
+			if s := [2]int{got.Players[0].Player.Score, got.Players[1].Player.Score}; s != tt.scores {
+				t.Errorf("scores = %v, want %v", s, tt.scores)
+			}
+			b := got.Ball
+			if b.X != GameWidth/2 || b.Y != GameHeight/2 {
+				t.Errorf("ball at (%v, %v) after the point, want the center", b.X, b.Y)
+			}
+			if math.Signbit(b.Vx) != math.Signbit(tt.serve) || !near(math.Hypot(b.Vx, b.Vy), ServeSpeed) {
+				t.Errorf("serve velocity = (%v, %v), want speed %v toward %v", b.Vx, b.Vy, ServeSpeed, tt.serve)
+			}
+			if angle := math.Atan(math.Abs(b.Vy / b.Vx)); angle > MaxServeAngle {
+				t.Errorf("serve angle = %v, want at most %v", angle, MaxServeAngle)
+			}
+		})
+	}
+}
+
+func TestStepWins(t *testing.T) {
+	g := running(api.Ball{X: 992, Y: 100, Vx: 5}, 400, 400)
+	g.state.Players[0].Player.Score = WinScore - 1
+	g.Step()
+	if !g.Over() {
+		t.Fatal("game not over at WinScore")
+	}
+	state := g.State()
+	if p := state.Players[0].Player; p.Score != WinScore || !p.Won {
+		t.Errorf("winner = %+v", p)
+	}
+	if state.Players[1].Player.Won {
+		t.Error("loser won too")
+	}
+	g.Step()
+	if after := g.State(); !reflect.DeepEqual(after, state) {
+		t.Errorf("state changed after the end: %+v", after)
+	}
+}
+
+func TestMovePaddles(t *testing.T) {
+	tests := []struct {
+		name  string
+		from  float64
+		moves []realtime.PaddleMove
+		steps int
+		want  float64
+	}{
+		{"down", 200, []realtime.PaddleMove{{Paddle: 0, Direction: realtime.Down, Moving: true}}, 3, 224},
+		{"up", 200, []realtime.PaddleMove{{Paddle: 0, Direction: realtime.Up, Moving: true}}, 3, 176},
+		{"stops at the top", 10, []realtime.PaddleMove{{Paddle: 0, Direction: realtime.Up, Moving: true}}, 3, 0},
+		{"stops at the bottom", 390, []realtime.PaddleMove{{Paddle: 0, Direction: realtime.Down, Moving: true}}, 3, GameHeight - PaddleHeight},
+		{
+			"stopped",
+			200,
+			[]realtime.PaddleMove{
+				{Paddle: 0, Direction: realtime.Down, Moving: true},
+				{Paddle: 0, Direction: realtime.Down, Moving: false},
+			},
+			3, 200,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := running(api.Ball{X: 500, Y: 250}, tt.from, 200)
+			for _, m := range tt.moves {
+				if err := g.Move(m); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for range tt.steps {
+				g.Step()
+			}
+			if got := g.State().Players[0].Paddle.Y; got != tt.want {
+				t.Errorf("paddle at %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	g := running(api.Ball{}, 200, 200)
+	if err := g.Move(realtime.PaddleMove{Paddle: 2, Direction: realtime.Up, Moving: true}); err == nil {
+		t.Error("moved a paddle that does not exist")
+	}
+	if err := g.Move(realtime.PaddleMove{Paddle: 0, Direction: "left", Moving: true}); err == nil {
+		t.Error("moved a paddle sideways")
+	}
+}
+
+func TestPausedGameStandsStill(t *testing.T) {
+	g := New("test", []string{"alice", "bob"}, 1)
+	before := g.State()
+	g.Move(realtime.PaddleMove{Paddle: 0, Direction: realtime.Down, Moving: true})
+	g.Step()
+	if after := g.State(); !reflect.DeepEqual(after, before) {
+		t.Errorf("paused game changed: %+v", after)
+	}
+}
+
+func TestNewPlacesPaddles(t *testing.T) {
+	tests := []struct {
+		players []string
+		want    []float64
+	}{
+		{[]string{"alice", "bob"}, []float64{200, 200}},
+		{[]string{"alice", "bob", "carol", "dave"}, []float64{75, 75, 325, 325}},
+	}
+	for _, tt := range tests {
+		state := New("test", tt.players, 1).State()
+		var got []float64
+		for _, p := range state.Players {
+			got = append(got, p.Paddle.Y)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d players: paddles at %v, want %v", len(tt.players), got, tt.want)
+		}
+		if !state.Pause {
+			t.Errorf("%d players: new game not paused", len(tt.players))
+		}
+	}
+}
+
+func TestAdvanceIsDeterministic(t *testing.T) {
+	play := func() *api.GameState {
+		g := New("test", []string{"alice", "bob"}, 42)
+		g.SetPaused(false)
+		// Uneven frames add up to the same ticks.
+		for _, d := range []time.Duration{5, 20, 3, 40, 16, 7} {
+			g.Advance(d * time.Millisecond)
+		}
+		for range 2000 {
+			g.Step()
+		}
+		return g.State()
+	}
+	a, b := play(), play()
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("same seed, different games:\n%+v\n%+v", a, b)
+	}
+
+	g := New("test", []string{"alice", "bob"}, 42)
+	g.SetPaused(false)
+	if n := g.Advance(Tick - time.Millisecond); n != 0 {
+		t.Errorf("Advance(Tick - 1ms) ran %d ticks", n)
+	}
+	if n := g.Advance(2 * Tick); n != 2 {
+		t.Errorf("Advance(2 Tick) after a remainder ran %d ticks, want 2", n)
+	}
+}
@@ -1,34 +1,32 @@
 package pong
 
 import (
-	"crypto/tls"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
 	"os/signal"
-	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"clipongo/pkg/api"
+	"clipongo/pkg/pong/engine"
+	"clipongo/pkg/realtime"
 
 	"github.com/gdamore/tcell/v2"
-	"github.com/gorilla/websocket"
 )
 
 const (
 	// Winscore
-	WinScore = 3
+	WinScore = engine.WinScore
 
-	// Game dimensions
-	GameWidth    = 1000 // Width of the game area
-	GameHeight   = 500  // Height of the game area
-	PaddleWidth  = 10   // Width of the paddles
-	PaddleHeight = 100  // Height of the paddles
-	BallSize     = 10   // Diameter of the ball
+	// Game dimensions, shared with the local engine
+	GameWidth    = engine.GameWidth
+	GameHeight   = engine.GameHeight
+	PaddleWidth  = engine.PaddleWidth
+	PaddleHeight = engine.PaddleHeight
+	BallSize     = engine.BallSize
 
 	// Game X Y pos
 	CenterX      = GameWidth / 2
@@ -45,6 +43,50 @@ var (
 	RightPaddleX = GameWidth - PaddleWidth - PaddleOffset
 )
 
+// Options tune a game. The zero value uses the defaults.
+type Options struct {
+	// ReconnectTimeout is how long to try reconnecting a lost connection
+	// before giving up: realtime.DefaultReconnectPolicy's when zero, never
+	// when negative.
+	ReconnectTimeout time.Duration
+	// HUD shows the network quality under the field from the start. H
+	// toggles it during the game.
+	HUD bool
+	// RawBall draws the ball where the last frame put it instead of
+	// predicting its motion between frames. R toggles it during the game.
+	RawBall bool
+	// KeyRepeat is the auto-repeat of the terminal, used to tell when a
+	// movement key is released. Zero fields keep DefaultKeyRepeat's.
+	KeyRepeat KeyRepeat
+	// Keymap binds the keys, DefaultKeymap when nil.
+	Keymap *Keymap
+	// Mouse makes the paddle follow the pointer's row. The keys still
+	// work, and take over until the pointer moves again.
+	Mouse bool
+	// PollInterval is how often a Spectator fetches the game when the
+	// WebSocket refuses them, DefaultPollInterval when zero.
+	PollInterval time.Duration
+}
+
+// keyRepeat returns KeyRepeat with the defaults filled in.
+func (o Options) keyRepeat() KeyRepeat {
+	repeat := DefaultKeyRepeat()
+	if o.KeyRepeat.Delay > 0 {
+		repeat.Delay = o.KeyRepeat.Delay
+	}
+	if o.KeyRepeat.Interval > 0 {
+		repeat.Interval = o.KeyRepeat.Interval
+	}
+	return repeat
+}
+
+func (o Options) keymap() *Keymap {
+	if o.Keymap == nil {
+		return DefaultKeymap()
+	}
+	return o.Keymap
+}
+
 type LocalGameState struct {
 	GameState api.GameState
 }
@@ -53,68 +95,172 @@ type winEvent struct {
 	Winner  string
 	YouWon  bool
 	EndTime time.Time
+	// Spectating shows Score instead of YouWon.
+	Spectating bool
+	Score      string
 }
 
-func StartGame(client *api.Client, gameID string, playerNumber int) {
+// StartGame runs the game gameID on the terminal until it ends or the
+// player quits. playerNumber is 1-based, or Spectator to watch the game.
+// Canceling ctx aborts the WebSocket dial and any pending API call.
+func StartGame(ctx context.Context, client *api.Client, gameID string, playerNumber int, opts Options) error {
 	screen, err := tcell.NewScreen()
 	if err != nil {
-		log.Fatalf("Failed to create screen: %v", err)
+		return fmt.Errorf("failed to create screen: %w", err)
 	}
 	if err := screen.Init(); err != nil {
-		log.Fatalf("Failed to initialize screen: %v", err)
+		return fmt.Errorf("failed to initialize screen: %w", err)
 	}
 	defer screen.Fini()
+	return RunGame(ctx, screen, client, gameID, playerNumber, opts)
+}
+
+// RunGame is StartGame on an already initialized screen, which is left
+// for the caller to finalize.
+func RunGame(ctx context.Context, screen tcell.Screen, client *api.Client, gameID string, playerNumber int, opts Options) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	screen.SetStyle(tcell.StyleDefault)
 	screen.Clear()
+	TermWidth, TermHeight = screen.Size()
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
 
-	gameStateChan := make(chan *api.GameState)
-	stopWS := make(chan struct{})
-	forceStopChan := make(chan struct{})
+	states := newStateMailbox()
 
 	winChan := make(chan winEvent)
 	defer close(winChan)
 	done := make(chan struct{})
-	go handleWinEvents(screen, winChan, done)
 
-	conn, err := listenGameWebSocket(client, gameID, gameStateChan, stopWS, forceStopChan)
-	if err != nil {
-		log.Fatalf("WebSocket connection failed: %v", err)
+	policy := realtime.DefaultReconnectPolicy()
+	if opts.ReconnectTimeout != 0 {
+		policy.Timeout = opts.ReconnectTimeout
+	}
+	spectating := playerNumber == Spectator
+	sessionPolicy := policy
+	if spectating {
+		// Polling takes over as soon as the WebSocket is closed, which is
+		// at once for the non-players the backend refuses.
+		sessionPolicy.Timeout = -1
+	}
+	session := realtime.NewSession(client, gameID, realtime.WithReconnect(sessionPolicy))
+	session.OnGameState(states.Put)
+	statusChan := make(chan realtime.Status)
+	session.OnStatus(func(st realtime.Status) {
+		select {
+		case statusChan <- st:
+		case <-ctx.Done():
+		}
+	})
+	pollInterval := opts.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = DefaultPollInterval
+	}
+	// lost is closed when the game can no longer be followed, lostErr
+	// tells why.
+	lost, lostErr := session.Done(), session.Err
+	poll := func() {
+		p := startPolling(ctx, client, gameID, pollInterval, policy.Timeout, states.Put)
+		lost, lostErr = p.Done(), p.Err
+	}
+	eventQueue := pollEvents(screen)
+	go handleWinEvents(screen, eventQueue, winChan, done)
+	// The screen is in raw mode, where Ctrl+C is a key: watch the quit
+	// keys until the game shows up.
+	keymap := opts.keymap()
+	quit := make(chan struct{})
+	stopWatching := watchQuit(eventQueue, keymap, func() {
+		close(quit)
+		cancel()
+	})
+	defer stopWatching()
+	if err := session.Connect(ctx); err != nil {
+		// quit is closed before the dial is canceled.
+		if isClosed(quit) {
+			return nil
+		}
+		if !spectating {
+			return fmt.Errorf("WebSocket connection failed: %w", err)
+		}
+		log.Printf("WebSocket refused, polling game %s instead: %v", gameID, err)
+		poll()
 	}
-
 	defer func() {
-		close(stopWS)
-		time.Sleep(100 * time.Millisecond)
-		conn.Close()
+		// Unblock the status handler before waiting for the reader to exit.
+		cancel()
+		session.Close()
 	}()
+
 	var localState *LocalGameState
-	select {
-	case initial := <-gameStateChan:
-		if initial == nil {
-			log.Fatal("Received nil initial state from WebSocket")
-		}
-		localState = &LocalGameState{
-			GameState: *initial,
+	for localState == nil {
+		select {
+		case <-states.Ready():
+			initial := states.Take()
+			if initial == nil {
+				return fmt.Errorf("received nil initial state from WebSocket")
+			}
+			localState = &LocalGameState{
+				GameState: *initial,
+			}
+		case <-lost:
+			if spectating && lost == session.Done() {
+				log.Printf("WebSocket closed, polling game %s instead: %v", gameID, session.Err())
+				poll()
+				continue
+			}
+			return fmt.Errorf("connection lost before the initial game state: %w", lostErr())
+		case <-quit:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(3 * time.Second):
+			return fmt.Errorf("timed out waiting for initial game state from WebSocket")
 		}
-	case <-time.After(3 * time.Second):
-		log.Fatal("Timed out waiting for initial game state from WebSocket")
 	}
-	eventQueue := make(chan tcell.Event, 100)
-	go func() {
-		for {
-			eventQueue <- screen.PollEvent()
-		}
-	}()
+	stopWatching()
+	if isClosed(quit) {
+		return nil
+	}
+	// The mailbox may have coalesced the first states with the last one.
+	if ev := detectWin(*localState, playerNumber); ev != nil {
+		winChan <- *ev
+		<-done
+		return nil
+	}
+
 	ticker := time.NewTicker(16 * time.Millisecond)
 	defer ticker.Stop()
 
-	tickerPaddle := time.NewTicker(50 * time.Millisecond)
-	defer tickerPaddle.Stop()
+	send := func(direction realtime.Direction, moving bool) {
+		sendPaddleMove(session, playerNumber-1, direction, moving)
+	}
+	input := newPaddleInput(opts.keyRepeat(), send)
+	mouse := newMouseSteer(send)
+	if opts.Mouse && !spectating {
+		screen.EnableMouse(tcell.MouseMotionEvents)
+		defer screen.DisableMouse()
+	}
 
-	keyStates := make(map[string]bool)
-	lastKeyPress := time.Now()
+	var resumeAt time.Time
+	// pausing is set while a pause or unpause request is in flight.
+	pausing := false
+	pauseResults := make(chan pauseResult)
+	request := func(pause bool) {
+		pausing = true
+		requestPause(ctx, client, gameID, pause, pauseResults)
+	}
+	var link realtime.Status
+	showHUD := opts.HUD
+	showHelp := false
+	rawBall := opts.RawBall
+	var ball ballPredictor
+	ball.Update(&localState.GameState, time.Now())
+	// A frame is dropped when a newer one arrives before it was drawn,
+	// either here or in the mailbox.
+	dropped, undrawn := 0, false
 
 	winDetected := false
 	var updated *api.GameState
@@ -125,31 +271,42 @@ gameLoop:
 		case event := <-eventQueue:
 			switch ev := event.(type) {
 			case *tcell.EventKey:
-				lastKeyPress = time.Now()
-				if ev.Key() == tcell.KeyCtrlSpace {
-					updated, err = client.Unpause(localState.GameState.ID)
-				}
-				if ev.Key() == tcell.KeyEsc || ev.Key() == tcell.KeyCtrlC {
-					return
+				action := keymap.Action(ev)
+				if spectating && (action == ActionPause || action == ActionPaddleUp || action == ActionPaddleDown) {
+					// Watching only.
+					continue
 				}
-				var action string
-				switch ev.Key() {
-				case tcell.KeyUp:
-					action = "paddle-up"
-				case tcell.KeyDown:
-					action = "paddle-down"
+				var direction realtime.Direction
+				switch action {
+				case ActionPause:
+					if !pausing {
+						resumeAt = togglePause(localState, resumeAt, request)
+					}
+				case ActionQuit:
+					input.Release()
+					mouse.Release()
+					return nil
+				case ActionHelp:
+					showHelp = !showHelp
+				case ActionHUD:
+					showHUD = !showHUD
+				case ActionRawBall:
+					rawBall = !rawBall
+				case ActionPaddleUp:
+					direction = realtime.Up
+				case ActionPaddleDown:
+					direction = realtime.Down
 				}
-				switch ev.Rune() {
-				case 'w', 'W':
-					action = "paddle-up"
-				case 's', 'S':
-					action = "paddle-down"
+				if direction != "" && !localState.GameState.Pause && link.State == realtime.Connected {
+					mouse.Release()
+					input.Press(direction, ev.When())
 				}
-				if action != "" && !keyStates[action] {
-					keyStates[action] = true
-					if !localState.GameState.Pause && localState.GameState.Players[0].Player.Score < 10 && localState.GameState.Players[1].Player.Score < 10 {
-						sendMoveFromAction(conn, playerNumber, action, true)
-					}
+			case *tcell.EventMouse:
+				if opts.Mouse && !localState.GameState.Pause && link.State == realtime.Connected {
+					_, row := ev.Position()
+					input.Release()
+					mouse.Point(row, TermHeight-2)
+					mouse.Steer(paddleY(localState, playerNumber))
 				}
 			case *tcell.EventResize:
 				w, h := ev.Size()
@@ -158,10 +315,23 @@ gameLoop:
 
 			}
 
-		case updated = <-gameStateChan:
-			if updated != nil {
+		case <-states.Ready():
+			if updated = states.Take(); updated != nil {
+				if undrawn {
+					dropped++
+				}
+				undrawn = true
 				localState.GameState = *updated
+				ball.Update(updated, time.Now())
 				localState.GameState.Pause = updated.Pause
+				if !updated.Pause {
+					// Someone else resumed the game.
+					resumeAt = time.Time{}
+					mouse.Steer(paddleY(localState, playerNumber))
+				} else {
+					input.Release()
+					mouse.Release()
+				}
 				if !winDetected {
 					if ev := detectWin(*localState, playerNumber); ev != nil {
 						winDetected = true
@@ -171,25 +341,62 @@ gameLoop:
 				}
 			}
 
-		case <-tickerPaddle.C:
-			if time.Since(lastKeyPress) > 16*time.Millisecond && !localState.GameState.Pause {
-				sendMoveFromAction(conn, playerNumber, "up", false)
+		case r := <-pauseResults:
+			pausing = false
+			if r.err != nil {
+				log.Printf("Failed to set pause to %v: %v", r.pause, r.err)
+			} else if r.state != nil {
+				localState.GameState.Pause = r.state.Pause
 			}
 
-		case <-ticker.C:
-			screen.Clear()
-			drawGameStateTcell(screen, &localState.GameState)
+		case link = <-statusChan:
+			if link.State == realtime.Reconnecting {
+				log.Printf("Connection lost, reconnecting (attempt %d): %v", link.Attempt, link.Err)
+			} else {
+				input.Resend()
+				mouse.Resend()
+			}
 
+		case now := <-ticker.C:
+			input.Tick(now)
+			if !resumeAt.IsZero() && !time.Now().Before(resumeAt) {
+				resumeAt = time.Time{}
+				request(false)
+			}
+			screen.Clear()
+			var hud *HUD
+			if showHUD {
+				hud = &HUD{Stats: session.Stats(), Dropped: dropped + states.Superseded()}
+			}
+			frame := localState.GameState
+			if !rawBall && link.State == realtime.Connected {
+				frame.Ball = ball.Position(time.Now())
+			}
+			drawGameStateTcell(screen, &frame, hud)
+			undrawn = false
+			switch {
+			case link.State == realtime.Reconnecting:
+				drawReconnectingOverlay(screen, time.Until(link.GiveUpAt))
+			case !resumeAt.IsZero():
+				drawCountdownOverlay(screen, time.Until(resumeAt))
+			case localState.GameState.Pause:
+				drawPausedOverlay(screen, keymap)
+			}
+			if showHelp {
+				drawHelpOverlay(screen, keymap)
+			}
 			screen.Show()
-		default:
-			keyStates["paddle-up"] = false
-			keyStates["paddle-down"] = false
-		case <-forceStopChan:
+		case <-lost:
+			if spectating && lost == session.Done() {
+				log.Printf("WebSocket closed, polling game %s instead: %v", gameID, session.Err())
+				poll()
+				continue
+			}
 			if ev := detectWin(*localState, playerNumber); ev != nil {
 				winChan <- *ev
 				break gameLoop
 			}
-			drawEndPage(screen, time.Now(), "CONNECTION LOST")
+			drawEndPage(screen, eventQueue, time.Now(), "CONNECTION LOST")
 			break gameLoop
 
 		case <-sigChan:
@@ -197,188 +404,196 @@ gameLoop:
 		}
 	}
 	<-done
-	return
+	return nil
 }
 
-func fetchInitialGameState(client *api.Client, gameID string, maxRetries int) (*api.GameState, error) {
-	var (
-		game *api.GameState
-		err  error
-	)
-	for i := 0; i < maxRetries; i++ {
-		game, err = client.GetGameState(gameID)
-		if err == nil {
-			return game, nil
+// pollEvents forwards the events of screen until it is finalized.
+func pollEvents(screen tcell.Screen) <-chan tcell.Event {
+	events := make(chan tcell.Event, 100)
+	go func() {
+		for {
+			ev := screen.PollEvent()
+			if ev == nil {
+				// The screen was finalized.
+				return
+			}
+			events <- ev
 		}
-		time.Sleep(time.Second)
-	}
-	return nil, fmt.Errorf("failed to get initial game state after %d attempts: %w", maxRetries, err)
+	}()
+	return events
 }
 
-func listenGameWebSocket(
-	client *api.Client,
-	gameID string,
-	gameStateChan chan<- *api.GameState,
-	stopChan <-chan struct{},
-	forceStopChan chan struct{},
-) (*websocket.Conn, error) {
-
-	url := fmt.Sprintf("wss://localhost:1443/ws/game/%s?token=%s", gameID, client.GetToken())
-
-	header := http.Header{}
-	header.Set("Authorization", "Bearer "+client.GetToken())
-	header.Set("Origin", "https://localhost:1443")
-
-	dialer := websocket.Dialer{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
-	}
-
-	conn, resp, err := dialer.Dial(url, header)
-	if err != nil {
-		log.Printf("WebSocket dial error: %v", err)
-		if resp != nil {
-			defer resp.Body.Close()
-			body, _ := io.ReadAll(resp.Body)
-			log.Printf("Response status: %s, body: %s", resp.Status, string(body))
-		}
-		return nil, err
-	}
-
+// watchQuit calls quit, once, if a quit key comes out of events before
+// the returned function is called. Other events are dropped.
+func watchQuit(events <-chan tcell.Event, keymap *Keymap, quit func()) (stop func()) {
+	stopping := make(chan struct{})
+	stopped := make(chan struct{})
 	go func() {
-		defer func() {
-			log.Printf("WebSocket reader exiting")
-			close(forceStopChan)
-		}()
+		defer close(stopped)
 		for {
 			select {
-			case <-stopChan:
-				log.Printf("Stop signal received: closing WebSocket reader")
-				return
-			default:
-				_, msg, err := conn.ReadMessage()
-				if err != nil {
-					if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
-						log.Printf("WebSocket closed normally")
-					} else {
-						log.Printf("WebSocket read error: %v", err)
-					}
+			case ev := <-events:
+				if key, ok := ev.(*tcell.EventKey); ok && keymap.Action(key) == ActionQuit {
+					quit()
 					return
 				}
+			case <-stopping:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stopping)
+			<-stopped
+		})
+	}
+}
 
-				if len(msg) == 0 {
-					log.Printf("Received empty message: ignoring")
-					continue
-				}
-				var typedMsg struct {
-					Type    string          `json:"type"`
-					Payload json.RawMessage `json:"payload"`
-				}
-				if err := json.Unmarshal(msg, &typedMsg); err != nil {
-					log.Printf("Failed to parse message type: %v", err)
-					continue
-				}
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
 
-				if typedMsg.Type != "game_state" {
-					log.Printf("Ignoring non-game_state: %s", typedMsg.Type)
-					continue
-				}
+// resumeCountdown is shown before a paused game resumes.
+const resumeCountdown = 3 * time.Second
+
+// togglePause pauses a running game at once but resumes a paused one only
+// after resumeCountdown, so that nobody is surprised by the ball moving.
+// Toggling during the countdown cancels it. It returns when the game should
+// resume, or the zero time if no countdown is running.
+//
+// The countdown only shows on the terminal of the player who resumes: the
+// protocol has no message to announce it, and the others only learn about
+// it from the first running frame.
+func togglePause(state *LocalGameState, resumeAt time.Time, request func(pause bool)) time.Time {
+	switch {
+	case !resumeAt.IsZero():
+		return time.Time{}
+	case state.GameState.Pause:
+		return time.Now().Add(resumeCountdown)
+	default:
+		request(true)
+		return time.Time{}
+	}
+}
 
-				var state api.GameState
-				if err := json.Unmarshal(typedMsg.Payload, &state); err != nil {
-					log.Printf("Failed to parse game state payload: %v", err)
-					continue
-				}
+// pauseResult is the answer of the server to a pause or unpause request.
+type pauseResult struct {
+	pause bool
+	state *api.GameState
+	err   error
+}
 
-				gameStateChan <- &state
-			}
+// requestPause pauses or resumes the game in the background, as the call
+// may be retried for a while, and sends the outcome to results.
+func requestPause(ctx context.Context, client *api.Client, gameID string, pause bool, results chan<- pauseResult) {
+	go func() {
+		r := pauseResult{pause: pause}
+		if pause {
+			r.state, r.err = client.Pause(ctx, gameID)
+		} else {
+			r.state, r.err = client.Unpause(ctx, gameID)
+		}
+		select {
+		case results <- r:
+		case <-ctx.Done():
 		}
 	}()
-
-	return conn, nil
 }
 
-func sendPaddleMove(conn *websocket.Conn, playerNum int, paddle, direction string, moving bool) {
-	msg := map[string]interface{}{
-		"type": "paddle_move",
-		"payload": map[string]interface{}{
-			"paddle":    playerNum - 1,
-			"direction": direction,
-			"moving":    moving,
-		},
-	}
-	if conn == nil {
-		log.Printf("Websocket is nill, skipping send")
-		return
-	}
-	if err := conn.WriteJSON(msg); err != nil {
+func sendPaddleMove(session *realtime.Session, paddle int, direction realtime.Direction, moving bool) {
+	move := realtime.PaddleMove{Paddle: paddle, Direction: direction, Moving: moving}
+	if err := session.SendPaddleMove(move); err != nil {
 		log.Printf("WebSocket send error: %v", err)
 	}
 }
 
-func sendMoveFromAction(conn *websocket.Conn, playerNum int, action string, moving bool) {
-	var paddle, direction string
-	if playerNum == 1 {
-		paddle = "paddle1"
-	} else {
-		paddle = "paddle2"
-	}
-	if strings.HasSuffix(action, "up") {
-		direction = "up"
-	} else {
-		direction = "down"
+// paddleY returns the top of the local player's paddle.
+func paddleY(state *LocalGameState, playerNumber int) float64 {
+	players := state.GameState.Players
+	if playerNumber < 1 || playerNumber > len(players) {
+		return 0
 	}
-	sendPaddleMove(conn, playerNum, paddle, direction, moving)
+	return players[playerNumber-1].Paddle.Y
 }
 
 func clearScreen() {
 	fmt.Print("\033[H\033[2J")
 }
 
+// detectWin reports the end of the game. Players play in teams, the left
+// one being players 1 and 3 and the right one players 2 and 4, so the
+// local player wins with their team. A Spectator gets the final score.
 func detectWin(state LocalGameState, playerNumber int) *winEvent {
-	p0 := state.GameState.Players[0].Player
-	p1 := state.GameState.Players[1].Player
+	players := state.GameState.Players
+	if len(players) < 2 {
+		return nil
+	}
 
-	var winner string
-	switch {
-	case p0.Won && !p1.Won:
-		winner = p0.Username
-	case p1.Won && !p0.Won:
-		winner = p1.Username
-	default:
-		if p0.Score >= WinScore || p1.Score >= WinScore {
-			if p0.Score > p1.Score {
-				winner = p0.Username
-			} else if p1.Score > p0.Score {
-				winner = p1.Username
+	winningTeam := -1
+	leftWon, rightWon := false, false
+	for i, p := range players {
+		if p.Player.Won {
+			if api.Team(i) == 0 {
+				leftWon = true
 			} else {
-				// tie
-				return nil
+				rightWon = true
 			}
+		}
+	}
+	// Team scores are kept on the first player of each team.
+	left, right := players[0].Player.Score, players[1].Player.Score
+	switch {
+	case leftWon && !rightWon:
+		winningTeam = 0
+	case rightWon && !leftWon:
+		winningTeam = 1
+	case left >= WinScore || right >= WinScore:
+		if left > right {
+			winningTeam = 0
+		} else if right > left {
+			winningTeam = 1
 		} else {
-			// no one won yet
+			// tie
 			return nil
 		}
+	default:
+		// no one won yet
+		return nil
 	}
-	localIdx := playerNumber - 1
-	localName := state.GameState.Players[localIdx].Player.Username
-	youWon := (localName == winner)
 
+	if playerNumber == Spectator {
+		return &winEvent{
+			Winner:     TeamName(players, winningTeam, 0),
+			EndTime:    time.Now(),
+			Spectating: true,
+			Score:      fmt.Sprintf("%s %d - %d %s", TeamName(players, 0, 0), left, right, TeamName(players, 1, 0)),
+		}
+	}
 	return &winEvent{
-		Winner:  winner,
-		YouWon:  youWon,
+		Winner:  TeamName(players, winningTeam, 0),
+		YouWon:  api.Team(playerNumber-1) == winningTeam,
 		EndTime: time.Now(),
 	}
 }
 
-func handleWinEvents(screen tcell.Screen, winChan <-chan winEvent, done chan<- struct{}) {
+func handleWinEvents(screen tcell.Screen, events <-chan tcell.Event, winChan <-chan winEvent, done chan<- struct{}) {
 	ev, ok := <-winChan
 	if !ok {
 		close(done)
 		return
 	}
-	drawWinPage(screen, ev.EndTime, ev.YouWon, ev.Winner)
-	drawEndPage(screen, time.Now(), "GAME ENDED")
+	if ev.Spectating {
+		drawResultPage(screen, events, ev.EndTime, ev.Score, ev.Winner)
+	} else {
+		drawWinPage(screen, events, ev.EndTime, ev.YouWon, ev.Winner)
+	}
+	drawEndPage(screen, events, time.Now(), "GAME ENDED")
 	close(done)
 }
@@ -0,0 +1,450 @@
+package pong_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"clipongo/pkg/api"
+	"clipongo/pkg/apitest"
+	"clipongo/pkg/pong"
+	"clipongo/pkg/realtime"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+const waitTimeout = 3 * time.Second
+
+// game is a RunGame in progress on a simulated 80x25 terminal.
+type game struct {
+	t      *testing.T
+	srv    *apitest.Server
+	screen tcell.SimulationScreen
+	id     string
+	done   chan error
+}
+
+// startGame creates a game between players on a fake backend and runs it
+// for the player at index player.
+func startGame(t *testing.T, player int, players ...string) *game {
+	t.Helper()
+	return startGameWith(t, pong.Options{}, player, players...)
+}
+
+// startGameWith is startGame with opts.
+func startGameWith(t *testing.T, opts pong.Options, player int, players ...string) *game {
+	t.Helper()
+	return startGameClient(t, opts, nil, player, players...)
+}
+
+// startGameClient is startGameWith with a client made with clientOpts.
+func startGameClient(t *testing.T, opts pong.Options, clientOpts []api.Option, player int, players ...string) *game {
+	t.Helper()
+	srv := apitest.NewServer()
+	t.Cleanup(srv.Close)
+
+	username := players[player]
+	client, err := api.NewClient(srv.URL, srv.Login(username), username, clientOpts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := srv.CreateGame(players...)
+
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(screen.Fini)
+	screen.SetSize(80, 25)
+
+	g := &game{t: t, srv: srv, screen: screen, id: state.ID, done: make(chan error, 1)}
+	go func() {
+		g.done <- pong.RunGame(context.Background(), screen, client, state.ID, player+1, opts)
+	}()
+	if err := srv.WaitJoined(state.ID, username, waitTimeout); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// text returns what the terminal shows, one line per row.
+func (g *game) text() string {
+	// GetContents hands out the front buffer without locking it, read the
+	// cells one at a time instead.
+	w, h := g.screen.Size()
+	var b strings.Builder
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, _, _, _ := g.screen.GetContent(x, y)
+			if r == 0 {
+				r = ' '
+			}
+			b.WriteRune(r)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// waitFor polls cond until it holds or fails the test after waitTimeout.
+func (g *game) waitFor(what string, cond func() bool) {
+	g.t.Helper()
+	deadline := time.Now().Add(waitTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			g.t.Fatalf("timed out waiting for %s; screen:\n%s", what, g.text())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (g *game) waitText(s string) {
+	g.t.Helper()
+	g.waitFor(fmt.Sprintf("%q", s), func() bool { return strings.Contains(g.text(), s) })
+}
+
+// exit presses keys until RunGame returns, as the end pages wait for them.
+func (g *game) exit() error {
+	g.t.Helper()
+	deadline := time.After(waitTimeout)
+	for {
+		select {
+		case err := <-g.done:
+			return err
+		case <-deadline:
+			g.t.Fatalf("RunGame did not return; screen:\n%s", g.text())
+		case <-time.After(20 * time.Millisecond):
+			g.screen.InjectKey(tcell.KeyEnter, 0, tcell.ModNone)
+		}
+	}
+}
+
+func TestRunGameRendersStateAndSendsMoves(t *testing.T) {
+	g := startGame(t, 0, "alice", "bob")
+	g.waitText("alice─0─────────0─bob")
+
+	// Moves are only sent once the game runs, bob has not joined yet.
+	state, _ := g.srv.Game(g.id)
+	state.Pause = false
+	if err := g.srv.SendState(g.id, state); err != nil {
+		t.Fatal(err)
+	}
+	g.waitFor("game to resume", func() bool { return !strings.Contains(g.text(), "PAUSED") })
+	// A held key: one press and its auto-repeats.
+	for range 3 {
+		g.screen.InjectKey(tcell.KeyRune, 'w', tcell.ModNone)
+	}
+	g.waitFor("paddle to stop", func() bool { return len(g.srv.Moves(g.id)) >= 2 })
+	want := []realtime.PaddleMove{
+		{Paddle: 0, Direction: realtime.Up, Moving: true},
+		{Paddle: 0, Direction: realtime.Up, Moving: false},
+	}
+	if moves := g.srv.Moves(g.id); !slices.Equal(moves, want) {
+		t.Errorf("moves = %+v, want %+v", moves, want)
+	}
+
+	state.Players[1].Player.Score = 2
+	if err := g.srv.SendState(g.id, state); err != nil {
+		t.Fatal(err)
+	}
+	g.waitText("alice─0─────────2─bob")
+
+	g.screen.InjectKey(tcell.KeyEsc, 0, tcell.ModNone)
+	if err := g.exit(); err != nil {
+		t.Errorf("RunGame: %v", err)
+	}
+}
+
+func TestRunGameShowsPauseOverlay(t *testing.T) {
+	g := startGame(t, 1, "alice", "bob")
+
+	state, _ := g.srv.Game(g.id)
+	state.Pause = true
+	if err := g.srv.SendState(g.id, state); err != nil {
+		t.Fatal(err)
+	}
+	g.waitText("PAUSED")
+
+	g.screen.InjectKey(tcell.KeyEsc, 0, tcell.ModNone)
+	if err := g.exit(); err != nil {
+		t.Errorf("RunGame: %v", err)
+	}
+}
+
+// stalled holds the requests whose path ends with suffix until release is
+// closed.
+type stalled struct {
+	suffix  string
+	release chan struct{}
+}
+
+func (s stalled) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(req.URL.Path, s.suffix) {
+		<-s.release
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestRunGamePausesInTheBackground(t *testing.T) {
+	slow := stalled{suffix: "/pause", release: make(chan struct{})}
+	g := startGameClient(t, pong.Options{}, []api.Option{api.WithRoundTripper(slow)}, 0, "alice", "bob")
+	state, _ := g.srv.Game(g.id)
+	state.Pause = false
+	if err := g.srv.SendState(g.id, state); err != nil {
+		t.Fatal(err)
+	}
+	g.waitFor("game to run", func() bool {
+		text := g.text()
+		return strings.Contains(text, "alice─0") && !strings.Contains(text, "PAUSED")
+	})
+
+	g.screen.InjectKey(tcell.KeyRune, 'p', tcell.ModNone)
+	// The server has not answered yet, the game still draws and reads keys.
+	g.screen.InjectKey(tcell.KeyRune, '?', tcell.ModNone)
+	g.waitText("KEYS")
+	if strings.Contains(g.text(), "PAUSED") {
+		t.Error("paused before the server answered")
+	}
+
+	close(slow.release)
+	g.screen.InjectKey(tcell.KeyRune, '?', tcell.ModNone)
+	g.waitText("PAUSED")
+	g.screen.InjectKey(tcell.KeyEsc, 0, tcell.ModNone)
+	if err := g.exit(); err != nil {
+		t.Errorf("RunGame: %v", err)
+	}
+}
+
+func TestRunGameQuitsWhileConnecting(t *testing.T) {
+	// A server that accepts connections and never answers.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+	client, err := api.NewClient("http://"+ln.Addr().String(), "token", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(screen.Fini)
+	done := make(chan error, 1)
+	go func() {
+		done <- pong.RunGame(context.Background(), screen, client, "g1", 1, pong.Options{})
+	}()
+
+	// Raw mode turns Ctrl+C into a key.
+	screen.InjectKey(tcell.KeyCtrlC, 0, tcell.ModCtrl)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("RunGame = %v, want nil after quitting", err)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatal("RunGame kept connecting after Ctrl+C")
+	}
+}
+
+func TestRunGameTogglesHUD(t *testing.T) {
+	g := startGame(t, 0, "alice", "bob")
+	g.waitText("alice─0─────────0─bob")
+
+	g.screen.InjectKey(tcell.KeyRune, 'h', tcell.ModNone)
+	g.waitText(" dropped ")
+	g.screen.InjectKey(tcell.KeyRune, 'H', tcell.ModNone)
+	g.waitFor("HUD to hide", func() bool { return !strings.Contains(g.text(), " dropped ") })
+
+	g.screen.InjectKey(tcell.KeyEsc, 0, tcell.ModNone)
+	if err := g.exit(); err != nil {
+		t.Errorf("RunGame: %v", err)
+	}
+}
+
+func TestRunGameUsesKeymap(t *testing.T) {
+	keymap, err := pong.NewKeymap(map[pong.Action][]string{
+		pong.ActionQuit:  {"q"},
+		pong.ActionPause: {"Space"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := startGameWith(t, pong.Options{Keymap: keymap}, 0, "alice", "bob")
+	g.waitText("PAUSED")
+	g.waitText("Space to resume")
+
+	g.screen.InjectKey(tcell.KeyF1, 0, tcell.ModNone)
+	g.waitText("KEYS")
+	g.waitText("q          Leave the game")
+
+	g.screen.InjectKey(tcell.KeyRune, 'Q', tcell.ModNone)
+	select {
+	case err := <-g.done:
+		if err != nil {
+			t.Errorf("RunGame: %v", err)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatalf("RunGame did not return on q; screen:\n%s", g.text())
+	}
+}
+
+func TestRunGameFollowsMouse(t *testing.T) {
+	g := startGameWith(t, pong.Options{Mouse: true}, 0, "alice", "bob")
+	state, _ := g.srv.Game(g.id)
+	state.Pause = false
+	if err := g.srv.SendState(g.id, state); err != nil {
+		t.Fatal(err)
+	}
+	g.waitFor("game to resume", func() bool {
+		text := g.text()
+		return strings.Contains(text, "alice─0") && !strings.Contains(text, "PAUSED")
+	})
+
+	// The bottom row of the field: the paddle goes all the way down.
+	g.screen.InjectMouse(10, 23, tcell.ButtonNone, tcell.ModNone)
+	g.waitFor("paddle to start", func() bool { return len(g.srv.Moves(g.id)) >= 1 })
+	state.Players[0].Paddle.Y = pong.GameHeight - pong.PaddleHeight
+	if err := g.srv.SendState(g.id, state); err != nil {
+		t.Fatal(err)
+	}
+	g.waitFor("paddle to stop", func() bool { return len(g.srv.Moves(g.id)) >= 2 })
+	want := []realtime.PaddleMove{
+		{Paddle: 0, Direction: realtime.Down, Moving: true},
+		{Paddle: 0, Direction: realtime.Down, Moving: false},
+	}
+	if moves := g.srv.Moves(g.id); !slices.Equal(moves, want) {
+		t.Errorf("moves = %+v, want %+v", moves, want)
+	}
+
+	g.screen.InjectKey(tcell.KeyEsc, 0, tcell.ModNone)
+	if err := g.exit(); err != nil {
+		t.Errorf("RunGame: %v", err)
+	}
+}
+
+func TestRunGameSpectates(t *testing.T) {
+	srv := apitest.NewServer()
+	t.Cleanup(srv.Close)
+	client, err := api.NewClient(srv.URL, srv.Login("carol"), "carol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := srv.CreateGame("alice", "bob")
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(screen.Fini)
+	screen.SetSize(80, 25)
+	g := &game{t: t, srv: srv, screen: screen, id: state.ID, done: make(chan error, 1)}
+	go func() {
+		opts := pong.Options{PollInterval: 10 * time.Millisecond}
+		g.done <- pong.RunGame(context.Background(), screen, client, state.ID, pong.Spectator, opts)
+	}()
+
+	// The WebSocket refuses carol: the game is polled instead.
+	g.waitText("alice─0─────────0─bob")
+	state.Players[1].Player.Score = 1
+	srv.SendState(state.ID, state)
+	g.waitText("alice─0─────────1─bob")
+
+	// Nothing is sent on behalf of a spectator.
+	g.screen.InjectKey(tcell.KeyRune, 'w', tcell.ModNone)
+	g.screen.InjectKey(tcell.KeyRune, 'p', tcell.ModNone)
+	g.screen.InjectKey(tcell.KeyRune, 'h', tcell.ModNone)
+	g.waitText(" dropped ")
+	if moves := srv.Moves(state.ID); len(moves) != 0 {
+		t.Errorf("spectator sent moves %+v", moves)
+	}
+	for _, req := range srv.Requests() {
+		if strings.HasPrefix(req, "POST") {
+			t.Errorf("spectator sent %s", req)
+		}
+	}
+
+	state.Players[1].Player.Score = pong.WinScore
+	state.Players[1].Player.Won = true
+	srv.SendState(state.ID, state)
+	g.waitText("GAME OVER")
+	g.waitText("alice 0 - 3 bob")
+	g.waitText("Winner: bob")
+	g.waitText("Press any key to continue")
+	if strings.Contains(g.text(), "YOU ") {
+		t.Errorf("spectator end screen:\n%s", g.text())
+	}
+	if err := g.exit(); err != nil {
+		t.Errorf("RunGame: %v", err)
+	}
+}
+
+func TestRunGameReconnects(t *testing.T) {
+	g := startGame(t, 0, "alice", "bob")
+	g.waitText("alice─0─────────0─bob")
+
+	// Keep the first resync failing long enough to see the overlay.
+	g.srv.Fail("GET", "/api/game/"+g.id, 503, 3)
+	g.srv.Disconnect(g.id, "alice")
+	g.waitText("RECONNECTING…")
+	g.waitFor("reconnection", func() bool { return !strings.Contains(g.text(), "RECONNECTING") })
+
+	state, _ := g.srv.Game(g.id)
+	state.Players[0].Player.Score = 1
+	if err := g.srv.SendState(g.id, state); err != nil {
+		t.Fatal(err)
+	}
+	g.waitText("alice─1─────────0─bob")
+
+	g.screen.InjectKey(tcell.KeyEsc, 0, tcell.ModNone)
+	if err := g.exit(); err != nil {
+		t.Errorf("RunGame: %v", err)
+	}
+}
+
+func TestRunGameEndsOnWin(t *testing.T) {
+	tests := []struct {
+		name    string
+		player  int
+		players []string
+		want    string
+		winner  string
+	}{
+		{"host wins", 0, []string{"alice", "bob"}, "YOU WON", "alice"},
+		{"guest loses", 1, []string{"alice", "bob"}, "YOU LOST", "alice"},
+		{"teammate wins", 2, []string{"alice", "bob", "carol", "dave"}, "YOU WON", "alice & carol"},
+		{"opponent loses", 3, []string{"alice", "bob", "carol", "dave"}, "YOU LOST", "alice & carol"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := startGame(t, tt.player, tt.players...)
+
+			state, _ := g.srv.Game(g.id)
+			state.Players[0].Player.Score = pong.WinScore
+			state.Players[0].Player.Won = true
+			if err := g.srv.SendState(g.id, state); err != nil {
+				t.Fatal(err)
+			}
+			g.waitText(tt.want)
+			g.waitText("Winner: " + tt.winner)
+
+			if err := g.exit(); err != nil {
+				t.Errorf("RunGame: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package pong
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"clipongo/pkg/realtime"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// KeyRepeat describes the auto-repeat of the terminal. Terminals only
+// report key presses, so a held key is recognized by its repeats and
+// considered released once they stop.
+type KeyRepeat struct {
+	// Delay is the wait before the first repeat of a held key.
+	Delay time.Duration
+	// Interval is the period of the following repeats.
+	Interval time.Duration
+}
+
+// DefaultKeyRepeat fits the usual X11, macOS and Windows settings. Run the
+// calibration to measure the actual ones.
+func DefaultKeyRepeat() KeyRepeat {
+	return KeyRepeat{Delay: 500 * time.Millisecond, Interval: 40 * time.Millisecond}
+}
+
+// releaseAfter is how long after a press, or its last repeat, the key is
+// considered released. Missing two repeats is allowed, as the terminal and
+// the event loop add their own jitter.
+func (k KeyRepeat) releaseAfter(repeating bool) time.Duration {
+	if repeating {
+		return 3 * k.Interval
+	}
+	return k.Delay + 2*k.Interval
+}
+
+// tapMove is how long a key press moves the paddle before its repeats
+// start, so that a tap nudges the paddle by a couple of steps.
+const tapMove = 2 * ServerTick
+
+// paddleInput turns key presses into paddle moves: a start when a movement
+// key is pressed and a stop when it is released, or when another movement
+// key is pressed. Until the repeats of a key start, it only moves the
+// paddle for tapMove.
+type paddleInput struct {
+	repeat KeyRepeat
+	send   func(direction realtime.Direction, moving bool)
+
+	held      realtime.Direction // empty when no key is held
+	repeating bool
+	moving    bool
+	stopAt    time.Time // end of the move of a press without repeats
+	releaseAt time.Time
+	last      realtime.Direction // of the last move sent
+}
+
+func newPaddleInput(repeat KeyRepeat, send func(realtime.Direction, bool)) *paddleInput {
+	return &paddleInput{repeat: repeat, send: send}
+}
+
+// Press handles a press, or a repeat, of the key moving toward direction.
+func (in *paddleInput) Press(direction realtime.Direction, now time.Time) {
+	if in.held == direction {
+		in.repeating = true
+		in.releaseAt = now.Add(in.repeat.releaseAfter(true))
+		in.start()
+		return
+	}
+	in.Release()
+	in.held = direction
+	in.repeating = false
+	in.stopAt = now.Add(tapMove)
+	in.releaseAt = now.Add(in.repeat.releaseAfter(false))
+	in.start()
+}
+
+// Tick stops the paddle of a press without repeats yet, and releases the
+// held key once its repeats stopped.
+func (in *paddleInput) Tick(now time.Time) {
+	if in.held == "" {
+		return
+	}
+	if !now.Before(in.releaseAt) {
+		in.Release()
+		return
+	}
+	if !in.repeating && !now.Before(in.stopAt) {
+		in.stop()
+	}
+}
+
+// Release stops the paddle if it is moving, e.g. when the game pauses.
+func (in *paddleInput) Release() {
+	if in.held == "" {
+		return
+	}
+	in.stop()
+	in.held = ""
+}
+
+func (in *paddleInput) start() {
+	if in.moving {
+		return
+	}
+	in.moving = true
+	in.last = in.held
+	in.send(in.held, true)
+}
+
+func (in *paddleInput) stop() {
+	if !in.moving {
+		return
+	}
+	in.moving = false
+	in.send(in.held, false)
+}
+
+// Resend sends the current state of the paddle again, to a server that
+// may have missed the last move while the connection was down.
+func (in *paddleInput) Resend() {
+	switch {
+	case in.moving:
+		in.send(in.held, true)
+	case in.last != "":
+		in.send(in.last, false)
+	}
+}
+
+// calibrationIdle ends the calibration once the key is released.
+const calibrationIdle = time.Second
+
+// CalibrateKeyRepeat measures the auto-repeat of the terminal while the
+// user holds a key.
+func CalibrateKeyRepeat() (KeyRepeat, error) {
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return KeyRepeat{}, fmt.Errorf("failed to create screen: %w", err)
+	}
+	if err := screen.Init(); err != nil {
+		return KeyRepeat{}, fmt.Errorf("failed to initialize screen: %w", err)
+	}
+	defer screen.Fini()
+	return MeasureKeyRepeat(screen)
+}
+
+// MeasureKeyRepeat is CalibrateKeyRepeat on an already initialized screen.
+func MeasureKeyRepeat(screen tcell.Screen) (KeyRepeat, error) {
+	screen.Clear()
+	TermWidth, TermHeight = screen.Size()
+	drawLines(screen, tcell.StyleDefault.Foreground(tcell.ColorWhite),
+		"Hold down any key for about two seconds, then release it.",
+		"Esc cancels.")
+	screen.Show()
+
+	events := make(chan *tcell.EventKey)
+	quit := make(chan struct{})
+	defer close(quit)
+	go func() {
+		for {
+			ev := screen.PollEvent()
+			if ev == nil {
+				return
+			}
+			if key, ok := ev.(*tcell.EventKey); ok {
+				select {
+				case events <- key:
+				case <-quit:
+					return
+				}
+			}
+		}
+	}()
+
+	var times []time.Time
+	var first *tcell.EventKey
+	idle := time.NewTimer(time.Hour)
+	defer idle.Stop()
+	for {
+		select {
+		case ev := <-events:
+			if ev.Key() == tcell.KeyEsc {
+				return KeyRepeat{}, fmt.Errorf("calibration canceled")
+			}
+			if first != nil && (ev.Key() != first.Key() || ev.Rune() != first.Rune()) {
+				continue
+			}
+			first = ev
+			times = append(times, ev.When())
+			idle.Reset(calibrationIdle)
+		case <-idle.C:
+			return keyRepeatFrom(times)
+		}
+	}
+}
+
+// keyRepeatFrom derives the auto-repeat from the times a held key was
+// reported at: the first gap is the delay, the median of the others the
+// interval.
+func keyRepeatFrom(times []time.Time) (KeyRepeat, error) {
+	if len(times) < 4 {
+		return KeyRepeat{}, fmt.Errorf("only %d key events: hold the key longer, or enable auto-repeat", len(times))
+	}
+	var gaps []time.Duration
+	for i := 2; i < len(times); i++ {
+		gaps = append(gaps, times[i].Sub(times[i-1]))
+	}
+	slices.Sort(gaps)
+	return KeyRepeat{
+		Delay:    times[1].Sub(times[0]),
+		Interval: gaps[len(gaps)/2],
+	}, nil
+}
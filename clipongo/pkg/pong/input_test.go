@@ -0,0 +1,139 @@
+package pong
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"clipongo/pkg/pong/engine"
+	"clipongo/pkg/realtime"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestPaddleInput(t *testing.T) {
+	repeat := KeyRepeat{Delay: 500 * time.Millisecond, Interval: 40 * time.Millisecond}
+	var sent []string
+	in := newPaddleInput(repeat, func(d realtime.Direction, moving bool) {
+		sent = append(sent, fmt.Sprintf("%s %v", d, moving))
+	})
+	t0 := time.Unix(1000, 0)
+	at := func(ms int) time.Time { return t0.Add(time.Duration(ms) * time.Millisecond) }
+
+	// Held: the press nudges the paddle, which moves again from the first
+	// repeat until the repeats stop.
+	in.Press(realtime.Up, at(0))
+	in.Tick(at(16))
+	in.Tick(at(32))
+	for ms := 500; ms <= 700; ms += 40 {
+		in.Press(realtime.Up, at(ms))
+		in.Tick(at(ms + 10))
+	}
+	in.Tick(at(800))
+	in.Tick(at(830))
+	// Tapped: a nudge, then released after the delay went by without
+	// repeats.
+	in.Press(realtime.Down, at(1000))
+	in.Tick(at(1032))
+	in.Tick(at(1500))
+	in.Tick(at(1580))
+	// Switched direction while held.
+	in.Press(realtime.Up, at(2000))
+	in.Press(realtime.Down, at(2010))
+	in.Release()
+	in.Release()
+
+	want := []string{
+		"up true", "up false", "up true", "up false",
+		"down true", "down false",
+		"up true", "up false", "down true", "down false",
+	}
+	if !slices.Equal(sent, want) {
+		t.Errorf("sent %q, want %q", sent, want)
+	}
+}
+
+func TestPaddleInputTap(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	var started, stopped time.Time
+	now := t0
+	in := newPaddleInput(DefaultKeyRepeat(), func(d realtime.Direction, moving bool) {
+		if moving {
+			started = now
+		} else {
+			stopped = now
+		}
+	})
+	in.Press(realtime.Down, now)
+	for now.Before(t0.Add(time.Second)) {
+		now = now.Add(ServerTick)
+		in.Tick(now)
+	}
+	if stopped.IsZero() {
+		t.Fatal("a tap never stopped the paddle")
+	}
+	// The server moves the paddle on each of its ticks until the stop.
+	distance := int(stopped.Sub(started)/ServerTick) * engine.PaddleSpeed
+	if distance > 2*engine.PaddleSpeed {
+		t.Errorf("a tap moves the paddle by %d, want at most %d", distance, 2*engine.PaddleSpeed)
+	}
+}
+
+func TestKeyRepeatFrom(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	var times []time.Time
+	for _, ms := range []int{0, 480, 520, 561, 600, 650, 680} {
+		times = append(times, t0.Add(time.Duration(ms)*time.Millisecond))
+	}
+	got, err := keyRepeatFrom(times)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := KeyRepeat{Delay: 480 * time.Millisecond, Interval: 40 * time.Millisecond}
+	if got != want {
+		t.Errorf("keyRepeatFrom = %+v, want %+v", got, want)
+	}
+
+	if _, err := keyRepeatFrom(times[:2]); err == nil {
+		t.Error("keyRepeatFrom accepted a single press")
+	}
+}
+
+func TestLocalPaddles(t *testing.T) {
+	tests := []struct {
+		name   string
+		keymap *Keymap
+		key    *tcell.EventKey
+		paddle int
+		dy     float64
+	}{
+		{"player up", DefaultKeymap(), tcell.NewEventKey(tcell.KeyUp, 0, tcell.ModNone), 0, -engine.PaddleSpeed},
+		{"player down", DefaultKeymap(), tcell.NewEventKey(tcell.KeyRune, 's', tcell.ModNone), 0, engine.PaddleSpeed},
+		{"left up", DefaultKeymap().HotSeat(), tcell.NewEventKey(tcell.KeyRune, 'w', tcell.ModNone), 0, -engine.PaddleSpeed},
+		{"left down", DefaultKeymap().HotSeat(), tcell.NewEventKey(tcell.KeyRune, 's', tcell.ModNone), 0, engine.PaddleSpeed},
+		{"right up", DefaultKeymap().HotSeat(), tcell.NewEventKey(tcell.KeyUp, 0, tcell.ModNone), 1, -engine.PaddleSpeed},
+		{"right down", DefaultKeymap().HotSeat(), tcell.NewEventKey(tcell.KeyDown, 0, tcell.ModNone), 1, engine.PaddleSpeed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			game := engine.New("local", []string{"alice", "bob"}, 1)
+			game.SetPaused(false)
+			before := game.State().Players
+			paddles := newLocalPaddles(DefaultKeyRepeat(), game)
+			paddles.Press(tt.keymap.Action(tt.key), time.Unix(1000, 0))
+			game.Step()
+
+			after := game.State().Players
+			for i := range after {
+				want := before[i].Paddle.Y
+				if i == tt.paddle {
+					want += tt.dy
+				}
+				if got := after[i].Paddle.Y; got != want {
+					t.Errorf("paddle %d at %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
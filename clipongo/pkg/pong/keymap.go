@@ -0,0 +1,316 @@
+package pong
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Action is what a key does during a game.
+type Action string
+
+const (
+	ActionPaddleUp   Action = "paddle-up"
+	ActionPaddleDown Action = "paddle-down"
+	ActionPause      Action = "pause"
+	ActionQuit       Action = "quit"
+	ActionHelp       Action = "help"
+	ActionHUD        Action = "hud"
+	ActionRawBall    Action = "raw-ball"
+
+	// The paddles of a hot-seat game, where two players share the
+	// keyboard. They may reuse the keys of the actions above.
+	ActionLeftUp    Action = "left-up"
+	ActionLeftDown  Action = "left-down"
+	ActionRightUp   Action = "right-up"
+	ActionRightDown Action = "right-down"
+)
+
+// Actions lists the actions in the order they are shown.
+var Actions = []Action{
+	ActionPaddleUp, ActionPaddleDown, ActionPause, ActionQuit, ActionHelp, ActionHUD, ActionRawBall,
+	ActionLeftUp, ActionLeftDown, ActionRightUp, ActionRightDown,
+}
+
+// The actions of each kind of game: a key may only be bound once within
+// each.
+var (
+	onlineActions = []Action{
+		ActionPaddleUp, ActionPaddleDown, ActionPause, ActionQuit, ActionHelp, ActionHUD, ActionRawBall,
+	}
+	hotSeatActions = []Action{
+		ActionLeftUp, ActionLeftDown, ActionRightUp, ActionRightDown, ActionPause, ActionQuit, ActionHelp,
+	}
+)
+
+// requiredActions cannot be left without a key: the game would be
+// unplayable, or impossible to leave.
+var requiredActions = []Action{
+	ActionPaddleUp, ActionPaddleDown, ActionQuit, ActionLeftUp, ActionLeftDown, ActionRightUp, ActionRightDown,
+}
+
+var descriptions = map[Action]string{
+	ActionPaddleUp:   "Move paddle up",
+	ActionPaddleDown: "Move paddle down",
+	ActionPause:      "Pause / resume",
+	ActionQuit:       "Leave the game",
+	ActionHelp:       "Show / hide this help",
+	ActionHUD:        "Show / hide the network quality",
+	ActionRawBall:    "Toggle ball prediction",
+	ActionLeftUp:     "Move the left paddle up (hot-seat)",
+	ActionLeftDown:   "Move the left paddle down (hot-seat)",
+	ActionRightUp:    "Move the right paddle up (hot-seat)",
+	ActionRightDown:  "Move the right paddle down (hot-seat)",
+}
+
+// DefaultBindings are the keys of each action when no keymap file
+// overrides them.
+func DefaultBindings() map[Action][]string {
+	return map[Action][]string{
+		ActionPaddleUp:   {"w", "Up"},
+		ActionPaddleDown: {"s", "Down"},
+		ActionPause:      {"p", "Ctrl-Space"},
+		ActionQuit:       {"Esc", "Ctrl-C"},
+		ActionHelp:       {"?", "F1"},
+		ActionHUD:        {"h"},
+		ActionRawBall:    {"r"},
+		ActionLeftUp:     {"w"},
+		ActionLeftDown:   {"s"},
+		ActionRightUp:    {"Up"},
+		ActionRightDown:  {"Down"},
+	}
+}
+
+// key is a key as tcell reports it: a special key, or KeyRune and the
+// lowercased rune.
+type key struct {
+	code tcell.Key
+	r    rune
+}
+
+// keyCodes maps the lowercased tcell key names, e.g. "ctrl-space", to
+// their key.
+var keyCodes = func() map[string]tcell.Key {
+	codes := make(map[string]tcell.Key, len(tcell.KeyNames))
+	for code, name := range tcell.KeyNames {
+		codes[strings.ToLower(name)] = code
+	}
+	return codes
+}()
+
+// parseKey reads a key name: a single character, which matches both cases
+// of a letter, "Space", or a tcell key name like "Up", "F1", "Esc" or
+// "Ctrl-C" ("Ctrl+C" works too).
+func parseKey(name string) (key, error) {
+	if utf8.RuneCountInString(name) == 1 {
+		r, _ := utf8.DecodeRuneInString(name)
+		if r < ' ' || r == utf8.RuneError {
+			return key{}, fmt.Errorf("invalid key %q", name)
+		}
+		return key{code: tcell.KeyRune, r: unicode.ToLower(r)}, nil
+	}
+	lower := strings.ToLower(strings.ReplaceAll(name, "+", "-"))
+	if lower == "space" {
+		return key{code: tcell.KeyRune, r: ' '}, nil
+	}
+	code, ok := keyCodes[lower]
+	if !ok {
+		return key{}, fmt.Errorf("unknown key %q", name)
+	}
+	return key{code: code}, nil
+}
+
+func eventKey(ev *tcell.EventKey) key {
+	if ev.Key() == tcell.KeyRune {
+		return key{code: tcell.KeyRune, r: unicode.ToLower(ev.Rune())}
+	}
+	return key{code: ev.Key()}
+}
+
+// Keymap binds keys to the actions of online games, or of hot-seat ones
+// for the keymap returned by HotSeat. The zero value has no bindings, use
+// DefaultKeymap, NewKeymap or LoadKeymap.
+type Keymap struct {
+	bindings map[Action][]string
+	shown    []Action
+	actions  map[key]Action
+	hotSeat  *Keymap
+}
+
+// DefaultKeymap returns the keymap of DefaultBindings.
+func DefaultKeymap() *Keymap {
+	k, err := NewKeymap(nil)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+// NewKeymap overrides DefaultBindings with bindings: an action that is
+// present replaces all its default keys, an empty list unbinds it. It
+// fails on unknown actions or keys, on a key bound to several actions of
+// the same kind of game, and when quit or a paddle move has no key.
+func NewKeymap(bindings map[Action][]string) (*Keymap, error) {
+	var errs []error
+	merged := DefaultBindings()
+	for _, action := range slices.Sorted(maps.Keys(bindings)) {
+		if _, ok := descriptions[action]; !ok {
+			errs = append(errs, fmt.Errorf("unknown action %q (want one of %s)", action, actionList()))
+			continue
+		}
+		merged[action] = bindings[action]
+	}
+
+	parsed := make(map[Action][]key)
+	for _, action := range Actions {
+		for _, name := range merged[action] {
+			pk, err := parseKey(name)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", action, err))
+				continue
+			}
+			if slices.Contains(parsed[action], pk) {
+				errs = append(errs, fmt.Errorf("%s: %q is listed twice", action, name))
+				continue
+			}
+			parsed[action] = append(parsed[action], pk)
+		}
+	}
+	// The actions shared by both kinds of game would report their
+	// conflicts twice.
+	seen := make(map[string]bool)
+	index := func(shown []Action) *Keymap {
+		k := &Keymap{bindings: merged, shown: shown, actions: make(map[key]Action)}
+		names := make(map[key]string)
+		for _, action := range shown {
+			for i, pk := range parsed[action] {
+				name := merged[action][i]
+				if other, ok := k.actions[pk]; ok {
+					var err error
+					if names[pk] != name {
+						err = fmt.Errorf("%q is bound to both %s (as %q) and %s", name, other, names[pk], action)
+					} else {
+						err = fmt.Errorf("%q is bound to both %s and %s", name, other, action)
+					}
+					if !seen[err.Error()] {
+						seen[err.Error()] = true
+						errs = append(errs, err)
+					}
+					continue
+				}
+				k.actions[pk] = action
+				names[pk] = name
+			}
+		}
+		return k
+	}
+	k := index(onlineActions)
+	k.hotSeat = index(hotSeatActions)
+	k.hotSeat.hotSeat = k.hotSeat
+	for _, action := range requiredActions {
+		if len(merged[action]) == 0 {
+			errs = append(errs, fmt.Errorf("%s has no key", action))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// LoadKeymap reads a keymap file: a JSON object of actions to lists of
+// keys, e.g. {"pause": ["Space"], "quit": ["q", "Esc"]}. The actions it
+// leaves out keep their default keys, and a missing file yields
+// DefaultKeymap.
+func LoadKeymap(path string) (*Keymap, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return DefaultKeymap(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keymap file: %w", err)
+	}
+	var bindings map[Action][]string
+	if err := json.Unmarshal(data, &bindings); err != nil {
+		return nil, fmt.Errorf("invalid keymap file %s: %w", path, err)
+	}
+	k, err := NewKeymap(bindings)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keymap file %s:\n%w", path, err)
+	}
+	return k, nil
+}
+
+// HotSeat returns the keymap of hot-seat games.
+func (k *Keymap) HotSeat() *Keymap {
+	return k.hotSeat
+}
+
+// Action returns the action bound to the key of ev, or "" if none is.
+func (k *Keymap) Action(ev *tcell.EventKey) Action {
+	return k.actions[eventKey(ev)]
+}
+
+// Keys returns the keys bound to action, as written in the keymap.
+func (k *Keymap) Keys(action Action) []string {
+	return k.bindings[action]
+}
+
+// Describe returns what action does, for the help and the keys command.
+func Describe(action Action) string {
+	return descriptions[action]
+}
+
+// Hint returns the keys of action joined with "or", e.g. "p or
+// Ctrl-Space", or "unbound".
+func (k *Keymap) Hint(action Action) string {
+	keys := k.Keys(action)
+	if len(keys) == 0 {
+		return "unbound"
+	}
+	return strings.Join(keys, " or ")
+}
+
+// Lines returns one line per action of the kind of game: its keys, then
+// what it does.
+func (k *Keymap) Lines() []string {
+	width := 0
+	for _, action := range k.shown {
+		width = max(width, utf8.RuneCountInString(k.Hint(action)))
+	}
+	lines := make([]string, len(k.shown))
+	for i, action := range k.shown {
+		hint := k.Hint(action)
+		pad := strings.Repeat(" ", width-utf8.RuneCountInString(hint))
+		lines[i] = fmt.Sprintf("%s%s  %s", hint, pad, Describe(action))
+	}
+	return lines
+}
+
+// Print writes the bindings to w, one action per line, with the action
+// names used in the keymap file.
+func (k *Keymap) Print(w io.Writer) error {
+	for _, action := range Actions {
+		if _, err := fmt.Fprintf(w, "%-12s %-24s %s\n", action, k.Hint(action), Describe(action)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func actionList() string {
+	names := make([]string, len(Actions))
+	for i, action := range Actions {
+		names[i] = string(action)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,129 @@
+package pong
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestKeymapAction(t *testing.T) {
+	k, err := NewKeymap(map[Action][]string{
+		ActionPause: {"Space", "Ctrl+P"},
+		ActionQuit:  {"q"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		ev   *tcell.EventKey
+		want Action
+	}{
+		{"rune", tcell.NewEventKey(tcell.KeyRune, 'w', tcell.ModNone), ActionPaddleUp},
+		{"uppercase rune", tcell.NewEventKey(tcell.KeyRune, 'W', tcell.ModShift), ActionPaddleUp},
+		{"special key", tcell.NewEventKey(tcell.KeyDown, 0, tcell.ModNone), ActionPaddleDown},
+		{"space", tcell.NewEventKey(tcell.KeyRune, ' ', tcell.ModNone), ActionPause},
+		{"ctrl key", tcell.NewEventKey(tcell.KeyCtrlP, 0, tcell.ModCtrl), ActionPause},
+		{"replaced default", tcell.NewEventKey(tcell.KeyEsc, 0, tcell.ModNone), ""},
+		{"override", tcell.NewEventKey(tcell.KeyRune, 'q', tcell.ModNone), ActionQuit},
+		{"unbound", tcell.NewEventKey(tcell.KeyRune, 'x', tcell.ModNone), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := k.Action(tt.ev); got != tt.want {
+				t.Errorf("Action = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeymapHotSeat(t *testing.T) {
+	k := DefaultKeymap().HotSeat()
+	tests := []struct {
+		ev   *tcell.EventKey
+		want Action
+	}{
+		{tcell.NewEventKey(tcell.KeyRune, 'w', tcell.ModNone), ActionLeftUp},
+		{tcell.NewEventKey(tcell.KeyRune, 'S', tcell.ModShift), ActionLeftDown},
+		{tcell.NewEventKey(tcell.KeyUp, 0, tcell.ModNone), ActionRightUp},
+		{tcell.NewEventKey(tcell.KeyDown, 0, tcell.ModNone), ActionRightDown},
+		{tcell.NewEventKey(tcell.KeyRune, 'p', tcell.ModNone), ActionPause},
+		{tcell.NewEventKey(tcell.KeyRune, 'h', tcell.ModNone), ""},
+	}
+	for _, tt := range tests {
+		if got := k.Action(tt.ev); got != tt.want {
+			t.Errorf("Action(%s) = %q, want %q", tt.ev.Name(), got, tt.want)
+		}
+	}
+	for _, line := range k.Lines() {
+		if strings.Contains(line, "network") {
+			t.Errorf("hot-seat help lists an online action: %q", line)
+		}
+	}
+}
+
+func TestNewKeymapErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		bindings map[Action][]string
+		want     []string
+	}{
+		{"unknown action", map[Action][]string{"jump": {"j"}}, []string{`unknown action "jump"`}},
+		{"unknown key", map[Action][]string{ActionHUD: {"Hyper-H"}}, []string{`hud: unknown key "Hyper-H"`}},
+		{"conflict with default", map[Action][]string{ActionQuit: {"P"}}, []string{`"P" is bound to both pause (as "p") and quit`}},
+		{"conflict", map[Action][]string{ActionHUD: {"Up"}}, []string{`"Up" is bound to both paddle-up and hud`}},
+		{"listed twice", map[Action][]string{ActionHUD: {"h", "H"}}, []string{`hud: "H" is listed twice`}},
+		{"required", map[Action][]string{ActionQuit: {}}, []string{"quit has no key"}},
+		{"hot-seat conflict", map[Action][]string{ActionRightUp: {"w"}}, []string{`"w" is bound to both left-up and right-up`}},
+		{
+			"all errors",
+			map[Action][]string{ActionPaddleDown: {}, ActionRawBall: {"w"}},
+			[]string{`"w" is bound to both paddle-up and raw-ball`, "paddle-down has no key"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeymap(tt.bindings)
+			if err == nil {
+				t.Fatal("NewKeymap succeeded")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadKeymap(t *testing.T) {
+	dir := t.TempDir()
+	k, err := LoadKeymap(filepath.Join(dir, "missing.json"))
+	if err != nil {
+		t.Fatalf("LoadKeymap without a file: %v", err)
+	}
+	if got := k.Hint(ActionPause); got != "p or Ctrl-Space" {
+		t.Errorf("default pause keys = %q", got)
+	}
+
+	path := filepath.Join(dir, "keys.json")
+	if err := os.WriteFile(path, []byte(`{"pause": ["Space"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if k, err = LoadKeymap(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := k.Hint(ActionPause); got != "Space" {
+		t.Errorf("pause keys = %q, want Space", got)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"pause": "Space"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeymap(path); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("LoadKeymap of an invalid file: %v", err)
+	}
+}
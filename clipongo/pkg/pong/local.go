@@ -0,0 +1,225 @@
+package pong
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"clipongo/pkg/pong/engine"
+	"clipongo/pkg/realtime"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Opponent is who plays the right paddle of a local game.
+type Opponent struct {
+	// Name is shown on the field.
+	Name string
+	// Skill is how well the AI plays.
+	Skill engine.Skill
+	// Wall replaces the AI with a wall that returns every ball, to
+	// practice.
+	Wall bool
+	// Human lets a second player take the right paddle on the same
+	// keyboard, with the hot-seat keys.
+	Human bool
+}
+
+// PlayLocal runs a game against opponent on the terminal, without any
+// server, until it ends or the player quits.
+func PlayLocal(ctx context.Context, player string, opponent Opponent, opts Options) error {
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return fmt.Errorf("failed to create screen: %w", err)
+	}
+	if err := screen.Init(); err != nil {
+		return fmt.Errorf("failed to initialize screen: %w", err)
+	}
+	defer screen.Fini()
+	return RunLocal(ctx, screen, player, opponent, opts)
+}
+
+// RunLocal is PlayLocal on an already initialized screen, which is left
+// for the caller to finalize. The network options are ignored.
+func RunLocal(ctx context.Context, screen tcell.Screen, player string, opponent Opponent, opts Options) error {
+	screen.SetStyle(tcell.StyleDefault)
+	screen.Clear()
+	TermWidth, TermHeight = screen.Size()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	winChan := make(chan winEvent)
+	defer close(winChan)
+	done := make(chan struct{})
+
+	seed := uint64(time.Now().UnixNano())
+	game := engine.New("local", []string{player, opponent.Name}, seed)
+	switch {
+	case opponent.Human:
+	case opponent.Wall:
+		game.SetWall(1)
+	default:
+		game.Control(1, engine.NewAI(1, opponent.Skill, seed))
+	}
+
+	keymap := opts.keymap()
+	paddles := newLocalPaddles(opts.keyRepeat(), game)
+	// Two players sharing the keyboard would fight over the pointer.
+	useMouse := opts.Mouse && !opponent.Human
+	// The end screen of a hot-seat game names the winner without taking
+	// sides.
+	viewer := 1
+	if opponent.Human {
+		keymap = keymap.HotSeat()
+		viewer = Spectator
+	}
+	if useMouse {
+		screen.EnableMouse(tcell.MouseMotionEvents)
+		defer screen.DisableMouse()
+	}
+
+	eventQueue := pollEvents(screen)
+	go handleWinEvents(screen, eventQueue, winChan, done)
+	ticker := time.NewTicker(engine.Tick)
+	defer ticker.Stop()
+
+	// The game starts with the resume countdown.
+	resumeAt := time.Now().Add(resumeCountdown)
+	showHelp := false
+	last := time.Now()
+
+gameLoop:
+	for {
+		select {
+		case event := <-eventQueue:
+			state := game.State()
+			switch ev := event.(type) {
+			case *tcell.EventKey:
+				switch action := keymap.Action(ev); action {
+				case ActionPause:
+					switch {
+					case !resumeAt.IsZero():
+						resumeAt = time.Time{}
+					case state.Pause:
+						resumeAt = time.Now().Add(resumeCountdown)
+					default:
+						game.SetPaused(true)
+						paddles.Release()
+					}
+				case ActionQuit:
+					return nil
+				case ActionHelp:
+					showHelp = !showHelp
+				default:
+					if !state.Pause {
+						paddles.Press(action, ev.When())
+					}
+				}
+			case *tcell.EventMouse:
+				if useMouse && !state.Pause {
+					_, row := ev.Position()
+					paddles.left.Release()
+					paddles.mouse.Point(row, TermHeight-2)
+					paddles.mouse.Steer(state.Players[0].Paddle.Y)
+				}
+			case *tcell.EventResize:
+				TermWidth, TermHeight = ev.Size()
+				screen.Sync()
+			}
+
+		case now := <-ticker.C:
+			paddles.Tick(now)
+			if !resumeAt.IsZero() && !now.Before(resumeAt) {
+				resumeAt = time.Time{}
+				game.SetPaused(false)
+			}
+			game.Advance(now.Sub(last))
+			last = now
+
+			state := game.State()
+			if game.Over() {
+				if ev := detectWin(LocalGameState{GameState: *state}, viewer); ev != nil {
+					winChan <- *ev
+					break gameLoop
+				}
+			}
+			paddles.mouse.Steer(state.Players[0].Paddle.Y)
+
+			screen.Clear()
+			drawGameStateTcell(screen, state, nil)
+			switch {
+			case !resumeAt.IsZero():
+				drawCountdownOverlay(screen, time.Until(resumeAt))
+			case state.Pause:
+				drawPausedOverlay(screen, keymap)
+			}
+			if showHelp {
+				drawHelpOverlay(screen, keymap)
+			}
+			screen.Show()
+
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-sigChan:
+			break gameLoop
+		}
+	}
+	<-done
+	return nil
+}
+
+// localPaddles moves the paddles of a local game: the player keys, the
+// left hot-seat keys and the mouse move the left paddle, the right
+// hot-seat keys the right one.
+type localPaddles struct {
+	left, right *paddleInput
+	mouse       *mouseSteer
+}
+
+func newLocalPaddles(repeat KeyRepeat, game *engine.Game) *localPaddles {
+	sender := func(paddle int) func(realtime.Direction, bool) {
+		return func(direction realtime.Direction, moving bool) {
+			game.Move(realtime.PaddleMove{Paddle: paddle, Direction: direction, Moving: moving})
+		}
+	}
+	return &localPaddles{
+		left:  newPaddleInput(repeat, sender(0)),
+		right: newPaddleInput(repeat, sender(1)),
+		mouse: newMouseSteer(sender(0)),
+	}
+}
+
+// Press moves the paddle of action, pressed at now. Other actions are
+// ignored.
+func (p *localPaddles) Press(action Action, now time.Time) {
+	switch action {
+	case ActionPaddleUp, ActionLeftUp:
+		p.mouse.Release()
+		p.left.Press(realtime.Up, now)
+	case ActionPaddleDown, ActionLeftDown:
+		p.mouse.Release()
+		p.left.Press(realtime.Down, now)
+	case ActionRightUp:
+		p.right.Press(realtime.Up, now)
+	case ActionRightDown:
+		p.right.Press(realtime.Down, now)
+	}
+}
+
+// Tick releases the keys that are no longer held.
+func (p *localPaddles) Tick(now time.Time) {
+	p.left.Tick(now)
+	p.right.Tick(now)
+}
+
+// Release stops both paddles.
+func (p *localPaddles) Release() {
+	p.left.Release()
+	p.right.Release()
+	p.mouse.Release()
+}
@@ -0,0 +1,68 @@
+package pong_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"clipongo/pkg/pong"
+	"clipongo/pkg/pong/engine"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestRunLocal(t *testing.T) {
+	tests := []struct {
+		name     string
+		opponent pong.Opponent
+		want     string
+	}{
+		{"vs computer", pong.Opponent{Name: "cpu", Skill: engine.Difficulties[0].Skill}, "alice─0─────────0─cpu"},
+		{"practice", pong.Opponent{Name: "wall", Wall: true}, "alice─0─────────0─wall"},
+		{"hot-seat", pong.Opponent{Name: "bob", Human: true}, "alice─0─────────0─bob"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			screen := tcell.NewSimulationScreen("")
+			if err := screen.Init(); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(screen.Fini)
+			screen.SetSize(80, 25)
+			g := &game{t: t, screen: screen, done: make(chan error, 1)}
+			ctx, cancel := context.WithCancel(context.Background())
+			stopped := make(chan struct{})
+			go func() {
+				defer close(stopped)
+				g.done <- pong.RunLocal(ctx, screen, "alice", tt.opponent, pong.Options{})
+			}()
+			// A failed test must not leave the game drawing on the
+			// screen of the next one. Once won, the game waits for a key
+			// instead of its context.
+			t.Cleanup(func() {
+				cancel()
+				for {
+					select {
+					case <-stopped:
+						return
+					case <-time.After(50 * time.Millisecond):
+						screen.InjectKey(tcell.KeyEsc, 0, tcell.ModNone)
+					}
+				}
+			})
+
+			g.waitText(tt.want)
+			g.waitText("RESUMING IN 3")
+
+			g.screen.InjectKey(tcell.KeyEsc, 0, tcell.ModNone)
+			select {
+			case err := <-g.done:
+				if err != nil {
+					t.Errorf("RunLocal: %v", err)
+				}
+			case <-time.After(waitTimeout):
+				t.Fatalf("RunLocal did not return; screen:\n%s", g.text())
+			}
+		})
+	}
+}
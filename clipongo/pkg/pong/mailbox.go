@@ -0,0 +1,60 @@
+package pong
+
+import (
+	"sync"
+
+	"clipongo/pkg/api"
+)
+
+// stateMailbox hands the newest game state from the WebSocket reader to
+// the game loop. Put never blocks: a state the loop did not take yet is
+// replaced, and counted as superseded, so a slow frame never backs up the
+// connection.
+type stateMailbox struct {
+	mu         sync.Mutex
+	state      *api.GameState
+	superseded int
+	ready      chan struct{}
+}
+
+func newStateMailbox() *stateMailbox {
+	return &stateMailbox{ready: make(chan struct{}, 1)}
+}
+
+// Put replaces the pending state with state.
+func (m *stateMailbox) Put(state *api.GameState) {
+	m.mu.Lock()
+	if m.state != nil {
+		m.superseded++
+	}
+	m.state = state
+	m.mu.Unlock()
+
+	select {
+	case m.ready <- struct{}{}:
+	default:
+		// The loop has yet to take the previous signal.
+	}
+}
+
+// Ready receives a value after Put. Take may still return nil, when the
+// state was taken along with an earlier signal.
+func (m *stateMailbox) Ready() <-chan struct{} {
+	return m.ready
+}
+
+// Take returns the pending state, or nil, and empties the mailbox.
+func (m *stateMailbox) Take() *api.GameState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state := m.state
+	m.state = nil
+	return state
+}
+
+// Superseded counts the states replaced before being taken.
+func (m *stateMailbox) Superseded() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.superseded
+}
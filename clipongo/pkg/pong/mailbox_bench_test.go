@@ -0,0 +1,84 @@
+package pong
+
+import (
+	"testing"
+	"time"
+
+	"clipongo/pkg/api"
+)
+
+// The reader gets a frame every frameInterval, as the backend ticks, while
+// drawing one takes renderCost: the renderer cannot keep up.
+const (
+	frameInterval = 500 * time.Microsecond
+	renderCost    = 3 * frameInterval
+)
+
+// benchmarkSlowRenderer reports how long the reader was blocked handing
+// each frame over, and how many frames behind the schedule the drawn ones
+// were. Frame i carries i in Ball.X.
+func benchmarkSlowRenderer(b *testing.B, put func(*api.GameState), closeInput func(), next func() (*api.GameState, bool)) {
+	var blocked time.Duration
+	start := time.Now()
+	go func() {
+		for i := 0; i < b.N; i++ {
+			time.Sleep(time.Until(start.Add(time.Duration(i) * frameInterval)))
+			t := time.Now()
+			put(&api.GameState{Ball: api.Ball{X: float64(i)}})
+			blocked += time.Since(t)
+		}
+		closeInput()
+	}()
+
+	var draws int
+	var stale float64
+	for {
+		state, ok := next()
+		if !ok {
+			break
+		}
+		due := float64(time.Since(start) / frameInterval)
+		stale += max(0, due-state.Ball.X)
+		draws++
+		time.Sleep(renderCost)
+	}
+	b.ReportMetric(float64(blocked.Nanoseconds())/float64(b.N), "reader-blocked-ns/frame")
+	b.ReportMetric(stale/float64(draws), "stale-frames/draw")
+}
+
+// BenchmarkSlowRenderer compares handing frames over an unbuffered
+// channel, where the reader waits for every draw and falls further behind
+// the server, with the mailbox, which only ever keeps the newest frame.
+func BenchmarkSlowRenderer(b *testing.B) {
+	b.Run("channel", func(b *testing.B) {
+		ch := make(chan *api.GameState)
+		benchmarkSlowRenderer(b,
+			func(s *api.GameState) { ch <- s },
+			func() { close(ch) },
+			func() (*api.GameState, bool) {
+				s, ok := <-ch
+				return s, ok
+			})
+	})
+	b.Run("mailbox", func(b *testing.B) {
+		m := newStateMailbox()
+		done := make(chan struct{})
+		benchmarkSlowRenderer(b,
+			m.Put,
+			func() { close(done) },
+			func() (*api.GameState, bool) {
+				for {
+					select {
+					case <-m.Ready():
+						if s := m.Take(); s != nil {
+							return s, true
+						}
+					case <-done:
+						s := m.Take()
+						return s, s != nil
+					}
+				}
+			})
+		b.ReportMetric(float64(m.Superseded())/float64(b.N), "superseded/frame")
+	})
+}
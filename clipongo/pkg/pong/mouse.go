@@ -0,0 +1,85 @@
+package pong
+
+import (
+	"clipongo/pkg/pong/engine"
+	"clipongo/pkg/realtime"
+)
+
+// mouseSteer moves the paddle toward the pointer with the same start and
+// stop messages as the keys. It starts once the paddle is more than the
+// dead zone away from the target and stops within half of it, so that
+// the paddle settles instead of oscillating around the target.
+type mouseSteer struct {
+	send func(direction realtime.Direction, moving bool)
+
+	target   float64 // paddle top, in game units
+	deadZone float64
+	active   bool               // the pointer set a target
+	moving   realtime.Direction // empty when stopped
+}
+
+func newMouseSteer(send func(realtime.Direction, bool)) *mouseSteer {
+	return &mouseSteer{send: send}
+}
+
+// Point sets the target to center the paddle on the terminal row, on a
+// screen whose field is innerHeight rows high.
+func (m *mouseSteer) Point(row, innerHeight int) {
+	if innerHeight <= 0 {
+		return
+	}
+	rowHeight := float64(GameHeight) / float64(innerHeight)
+	// The center of the row, below the top border.
+	center := (float64(row-1) + 0.5) * rowHeight
+	m.target = min(max(center-PaddleHeight/2, 0), GameHeight-PaddleHeight)
+	// Closer than a row is as good as the pointer can tell, and a paddle
+	// keeps moving for a couple of ticks after it is told to stop.
+	m.deadZone = max(rowHeight, 2*engine.PaddleSpeed)
+	m.active = true
+}
+
+// Steer starts, stops or turns the paddle, whose top is at y, toward the
+// target.
+func (m *mouseSteer) Steer(y float64) {
+	if !m.active {
+		return
+	}
+	diff := m.target - y
+	var want realtime.Direction
+	switch {
+	case m.moving == realtime.Down && diff > m.deadZone/2,
+		m.moving != realtime.Down && diff > m.deadZone:
+		want = realtime.Down
+	case m.moving == realtime.Up && -diff > m.deadZone/2,
+		m.moving != realtime.Up && -diff > m.deadZone:
+		want = realtime.Up
+	}
+	if want == m.moving {
+		return
+	}
+	if m.moving != "" {
+		m.send(m.moving, false)
+	}
+	if want != "" {
+		m.send(want, true)
+	}
+	m.moving = want
+}
+
+// Release stops the paddle and forgets the target, e.g. when the game
+// pauses or a key takes over.
+func (m *mouseSteer) Release() {
+	if m.moving != "" {
+		m.send(m.moving, false)
+		m.moving = ""
+	}
+	m.active = false
+}
+
+// Resend sends the current move again, to a server that may have missed
+// it while the connection was down.
+func (m *mouseSteer) Resend() {
+	if m.moving != "" {
+		m.send(m.moving, true)
+	}
+}
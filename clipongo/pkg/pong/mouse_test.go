@@ -0,0 +1,56 @@
+package pong
+
+import (
+	"slices"
+	"testing"
+
+	"clipongo/pkg/realtime"
+)
+
+func TestMouseSteer(t *testing.T) {
+	type move struct {
+		Direction realtime.Direction
+		Moving    bool
+	}
+	// 50 rows make a row 10 units high: the dead zone is 2 paddle steps.
+	const innerHeight = 50
+	tests := []struct {
+		name   string
+		row    int
+		paddle []float64 // successive tops of the paddle
+		want   []move
+	}{
+		{"within the dead zone", 26, []float64{190}, nil},
+		{"down then stop", 46, []float64{200, 300, 350, 395}, []move{{realtime.Down, true}, {realtime.Down, false}}},
+		{"up then stop", 6, []float64{200, 100, 7}, []move{{realtime.Up, true}, {realtime.Up, false}}},
+		{"overshoot turns back", 26, []float64{300, 180, 195}, []move{{realtime.Up, true}, {realtime.Up, false}, {realtime.Down, true}}},
+		{"clamped to the field", 1, []float64{20, 5}, []move{{realtime.Up, true}, {realtime.Up, false}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []move
+			m := newMouseSteer(func(d realtime.Direction, moving bool) {
+				got = append(got, move{d, moving})
+			})
+			m.Point(tt.row, innerHeight)
+			for _, y := range tt.paddle {
+				m.Steer(y)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("moves = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMouseSteerRelease(t *testing.T) {
+	var got []bool
+	m := newMouseSteer(func(_ realtime.Direction, moving bool) { got = append(got, moving) })
+	m.Point(46, 50)
+	m.Steer(0)
+	m.Release()
+	m.Steer(0)
+	if !slices.Equal(got, []bool{true, false}) {
+		t.Errorf("moves = %v, want a start then a stop", got)
+	}
+}
@@ -0,0 +1,95 @@
+package pong
+
+import (
+	"math"
+	"time"
+
+	"clipongo/pkg/api"
+	"clipongo/pkg/pong/engine"
+)
+
+const (
+	// ServerTick is the period of the backend game loop, the unit of the
+	// ball velocity.
+	ServerTick = engine.Tick
+
+	// maxExtrapolation bounds how far ahead of the last frame the ball is
+	// predicted: beyond that it may well have hit a paddle or scored.
+	maxExtrapolation = 6 * ServerTick
+	// correctionTime is how long a misprediction takes to fade out.
+	correctionTime = 100 * time.Millisecond
+	// snapDistance is the misprediction above which the ball jumps to
+	// the received position, as after a serve.
+	snapDistance = 100.0
+)
+
+// ballPredictor tells where to draw the ball between server frames. It
+// moves the last received ball along its velocity, bouncing on the top and
+// bottom walls like the backend does, and eases into each new frame
+// instead of jumping to it.
+type ballPredictor struct {
+	ball   api.Ball
+	paused bool
+	at     time.Time // when ball was received
+	// errX, errY are how far the drawn ball was from the received one,
+	// absorbed over correctionTime.
+	errX, errY float64
+}
+
+// Update takes the ball of a new frame, received at now.
+func (p *ballPredictor) Update(state *api.GameState, now time.Time) {
+	if !p.at.IsZero() && !p.paused && !state.Pause {
+		drawn := p.Position(now)
+		dx, dy := drawn.X-state.Ball.X, drawn.Y-state.Ball.Y
+		if math.Hypot(dx, dy) < snapDistance {
+			p.errX, p.errY = dx, dy
+		} else {
+			p.errX, p.errY = 0, 0
+		}
+	} else {
+		p.errX, p.errY = 0, 0
+	}
+	p.ball, p.paused, p.at = state.Ball, state.Pause, now
+}
+
+// Position returns the ball to draw at now.
+func (p *ballPredictor) Position(now time.Time) api.Ball {
+	if p.paused || p.at.IsZero() {
+		return p.ball
+	}
+	elapsed := min(max(now.Sub(p.at), 0), maxExtrapolation)
+	ticks := float64(elapsed) / float64(ServerTick)
+
+	b := p.ball
+	b.X += b.Vx * ticks
+	b.Y, b.Vy = reflect(b.Y+b.Vy*ticks, b.Vy, BallSize/2, GameHeight-BallSize/2)
+
+	if fade := 1 - float64(now.Sub(p.at))/float64(correctionTime); fade > 0 {
+		b.X += p.errX * fade
+		b.Y += p.errY * fade
+	}
+	b.X = min(max(b.X, BallSize/2), GameWidth-BallSize/2)
+	b.Y = min(max(b.Y, BallSize/2), GameHeight-BallSize/2)
+	return b
+}
+
+// reflect folds y back into [lo, hi] as if it bounced on both bounds,
+// flipping the velocity vy on each bounce.
+func reflect(y, vy, lo, hi float64) (float64, float64) {
+	span := hi - lo
+	if span <= 0 {
+		return lo, vy
+	}
+	m := math.Mod(y-lo, 2*span)
+	if m < 0 {
+		m += 2 * span
+	}
+	bounces := int(math.Floor((y - lo) / span))
+	if bounces%2 != 0 {
+		vy = -vy
+	}
+	if m > span {
+		m = 2*span - m
+	}
+	return lo + m, vy
+}
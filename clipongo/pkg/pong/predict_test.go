@@ -0,0 +1,62 @@
+package pong
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"clipongo/pkg/api"
+)
+
+func TestBallPredictorPosition(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	tests := []struct {
+		name   string
+		ball   api.Ball
+		paused bool
+		after  time.Duration
+		wantX  float64
+		wantY  float64
+	}{
+		{"moves with velocity", api.Ball{X: 500, Y: 250, Vx: 5, Vy: -2}, false, 2 * ServerTick, 510, 246},
+		{"bounces on top wall", api.Ball{X: 500, Y: 7, Vx: 0, Vy: -4}, false, ServerTick, 500, 7},
+		{"bounces on bottom wall", api.Ball{X: 500, Y: 490, Vx: 0, Vy: 6}, false, 2 * ServerTick, 500, 488},
+		{"stops extrapolating", api.Ball{X: 500, Y: 250, Vx: 5, Vy: 0}, false, time.Second, 530, 250},
+		{"stays put when paused", api.Ball{X: 500, Y: 250, Vx: 5, Vy: 5}, true, time.Second, 500, 250},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p ballPredictor
+			p.Update(&api.GameState{Ball: tt.ball, Pause: tt.paused}, t0)
+			got := p.Position(t0.Add(tt.after))
+			if math.Abs(got.X-tt.wantX) > 1e-9 || math.Abs(got.Y-tt.wantY) > 1e-9 {
+				t.Errorf("Position = (%v, %v), want (%v, %v)", got.X, got.Y, tt.wantX, tt.wantY)
+			}
+		})
+	}
+}
+
+func TestBallPredictorCorrection(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	var p ballPredictor
+	p.Update(&api.GameState{Ball: api.Ball{X: 500, Y: 250, Vx: 5}}, t0)
+
+	// The server says the ball is 10 units behind the prediction: the
+	// drawn ball starts where it was and catches up.
+	now := t0.Add(2 * ServerTick)
+	p.Update(&api.GameState{Ball: api.Ball{X: 500, Y: 250, Vx: 5}}, now)
+	if got := p.Position(now).X; got != 510 {
+		t.Errorf("X right after the correction = %v, want 510", got)
+	}
+	half := now.Add(correctionTime / 2)
+	want := 500 + 5*float64(correctionTime/2)/float64(ServerTick) + 10.0/2
+	if got := p.Position(half).X; math.Abs(got-want) > 1e-9 {
+		t.Errorf("X halfway through the correction = %v, want %v", got, want)
+	}
+
+	// A serve moves the ball too far to ease into it.
+	p.Update(&api.GameState{Ball: api.Ball{X: 100, Y: 250, Vx: -5}}, now)
+	if got := p.Position(now).X; got != 100 {
+		t.Errorf("X after a serve = %v, want 100", got)
+	}
+}
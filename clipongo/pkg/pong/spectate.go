@@ -0,0 +1,88 @@
+package pong
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"clipongo/pkg/api"
+)
+
+// Spectator is the player number that watches a game without a paddle:
+// nothing is ever sent, and the end screen names both teams.
+const Spectator = 0
+
+// DefaultPollInterval is how often a spectator refused by the WebSocket
+// fetches the game. Past maxExtrapolation the ball stops between polls.
+const DefaultPollInterval = 100 * time.Millisecond
+
+// poller fetches the state of a game at a fixed rate, for spectators: the
+// backend only accepts the WebSocket of players.
+type poller struct {
+	done chan struct{}
+	mu   sync.Mutex
+	err  error
+}
+
+// startPolling puts the state of gameID every interval until ctx is done,
+// the game is gone or fetching it fails for giveUp in a row.
+func startPolling(ctx context.Context, client *api.Client, gameID string, interval, giveUp time.Duration, put func(*api.GameState)) *poller {
+	p := &poller{done: make(chan struct{})}
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var failingSince time.Time
+		for {
+			state, err := client.GetGameState(ctx, gameID)
+			switch {
+			case err == nil:
+				failingSince = time.Time{}
+				put(state)
+			case ctx.Err() != nil:
+				p.setErr(ctx.Err())
+				return
+			case errors.Is(err, api.ErrNotFound):
+				p.setErr(fmt.Errorf("game %s is over: %w", gameID, err))
+				return
+			default:
+				if failingSince.IsZero() {
+					failingSince = time.Now()
+				}
+				if giveUp >= 0 && time.Since(failingSince) > giveUp {
+					p.setErr(fmt.Errorf("gave up polling game %s after %s: %w", gameID, giveUp, err))
+					return
+				}
+				log.Printf("Failed to poll game %s: %v", gameID, err)
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				p.setErr(ctx.Err())
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// Done is closed once polling stopped.
+func (p *poller) Done() <-chan struct{} {
+	return p.done
+}
+
+// Err tells why polling stopped.
+func (p *poller) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+func (p *poller) setErr(err error) {
+	p.mu.Lock()
+	p.err = err
+	p.mu.Unlock()
+}
@@ -2,14 +2,24 @@ package pong
 
 import (
 	"clipongo/pkg/api"
+	"clipongo/pkg/realtime"
 	"fmt"
+	"math"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gdamore/tcell/v2"
 )
 
-func drawGameStateTcell(screen tcell.Screen, game *api.GameState) {
+// HUD is the network quality line drawn on the bottom border.
+type HUD struct {
+	realtime.Stats
+	// Dropped counts the frames replaced by a newer one before being drawn.
+	Dropped int
+}
+
+func drawGameStateTcell(screen tcell.Screen, game *api.GameState, hud *HUD) {
 
 	width := TermWidth
 	height := TermHeight
@@ -48,9 +58,10 @@ func drawGameStateTcell(screen tcell.Screen, game *api.GameState) {
 		return int(float64(y) * float64(innerHeight) / float64(GameHeight))
 	}
 
-	topLine := fmt.Sprintf("%s─%d─────────%d─%s", Truncate(game.Players[0].Player.Username, 10), game.Players[0].Player.Score, game.Players[1].Player.Score, Truncate(game.Players[1].Player.Username, 10))
-	startX := (innerWidth-len(topLine))/2 + 1
-	for i, r := range topLine {
+	topLine := fmt.Sprintf("%s─%d─────────%d─%s", TeamName(game.Players, 0, 10), game.Players[0].Player.Score, game.Players[1].Player.Score, TeamName(game.Players, 1, 10))
+	topRunes := []rune(topLine)
+	startX := (innerWidth-len(topRunes))/2 + 1
+	for i, r := range topRunes {
 		screen.SetContent(startX+i, 0, r, nil, scoreStyle)
 	}
 
@@ -69,20 +80,35 @@ func drawGameStateTcell(screen tcell.Screen, game *api.GameState) {
 		return int(y/float64(maxGameY)*float64(maxScreenY)) + 1
 	}
 
-	p1x := scaleX(LeftPaddleX) + 1
-	p1y := scalePaddleY(game.Players[0].Paddle.Y)
-	for y := 0; y < paddleHeight; y++ {
-		py := p1y + y
-		if py > 0 && py < height-1 {
-			screen.SetContent(p1x, py, '█', nil, paddleStyle)
+	teams := len(game.Players) > 2
+	for i, player := range game.Players {
+		px := scaleX(LeftPaddleX) + 1
+		if api.Team(i) == 1 {
+			px = scaleX(RightPaddleX) + 1
 		}
-	}
-	p2x := scaleX(RightPaddleX) + 1
-	p2y := scalePaddleY(game.Players[1].Paddle.Y)
-	for y := 0; y < paddleHeight; y++ {
-		py := p2y + y
-		if py > 0 && py < height-1 {
-			screen.SetContent(p2x, py, '█', nil, paddleStyle)
+		top := scalePaddleY(player.Paddle.Y)
+		style := paddleStyle
+		if teams {
+			style = tcell.StyleDefault.Foreground(teamPaddleColors[i%len(teamPaddleColors)])
+		}
+		for y := 0; y < paddleHeight; y++ {
+			py := top + y
+			if py > 0 && py < height-1 {
+				screen.SetContent(px, py, '█', nil, style)
+			}
+		}
+		if teams {
+			// Tell teammates apart with their name next to the paddle.
+			label := Truncate(player.Player.Username, 10)
+			lx := px + 2
+			if api.Team(i) == 1 {
+				lx = px - 1 - len([]rune(label))
+			}
+			if top > 0 && top < height-1 {
+				for j, r := range []rune(label) {
+					screen.SetContent(lx+j, top, r, nil, style)
+				}
+			}
 		}
 	}
 
@@ -91,6 +117,49 @@ func drawGameStateTcell(screen tcell.Screen, game *api.GameState) {
 	if ballX > 0 && ballX < width-1 && ballY > 0 && ballY < height-1 {
 		screen.SetContent(ballX, ballY, '●', nil, ballStyle)
 	}
+
+	if hud != nil {
+		hudLine := []rune(hud.String())
+		hx := (innerWidth-len(hudLine))/2 + 1
+		for i, r := range hudLine {
+			screen.SetContent(hx+i, height-1, r, nil, centerLineStyle)
+		}
+	}
+}
+
+func (h *HUD) String() string {
+	ping, jitter := "--", "--"
+	if h.RTT > 0 {
+		ping = fmt.Sprintf("%dms", h.RTT.Milliseconds())
+		jitter = fmt.Sprintf("%dms", h.Jitter.Milliseconds())
+	}
+	return fmt.Sprintf(" ping %s  jitter %s  %.0f fps  %d dropped ", ping, jitter, h.FPS, h.Dropped)
+}
+
+// teamPaddleColors distinguishes the four paddles of a 2v2 game, indexed
+// like GameState.Players.
+var teamPaddleColors = []tcell.Color{
+	tcell.ColorGreen,
+	tcell.ColorAqua,
+	tcell.ColorFuchsia,
+	tcell.ColorOrange,
+}
+
+// TeamName joins the usernames of the players of team, each cut to
+// maxRunes unless it is zero.
+func TeamName(players []api.GamePlayer, team int, maxRunes int) string {
+	var names []string
+	for i, p := range players {
+		if api.Team(i) != team {
+			continue
+		}
+		name := p.Player.Username
+		if maxRunes > 0 {
+			name = Truncate(name, maxRunes)
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, " & ")
 }
 
 func Truncate(s string, maxRunes int) string {
@@ -101,7 +170,19 @@ func Truncate(s string, maxRunes int) string {
 	return string(rs[:maxRunes])
 }
 
-func drawPausedOverlay(screen tcell.Screen) {
+// drawLines draws lines centered on the screen.
+func drawLines(screen tcell.Screen, style tcell.Style, lines ...string) {
+	y := (TermHeight - len(lines)) / 2
+	for row, line := range lines {
+		rs := []rune(line)
+		x := (TermWidth - len(rs)) / 2
+		for i, r := range rs {
+			screen.SetContent(x+i, y+row, r, nil, style)
+		}
+	}
+}
+
+func drawPausedOverlay(screen tcell.Screen, keymap *Keymap) {
 	msg := " PAUSED "
 	style := tcell.StyleDefault.Foreground(tcell.ColorRed).Bold(true)
 	x := (TermWidth - len(msg)) / 2
@@ -109,122 +190,129 @@ func drawPausedOverlay(screen tcell.Screen) {
 	for i, r := range msg {
 		screen.SetContent(x+i, y, r, nil, style)
 	}
+
+	hint := []rune(fmt.Sprintf(" %s to resume ", keymap.Hint(ActionPause)))
+	hintStyle := tcell.StyleDefault.Foreground(tcell.ColorWhite)
+	hx := (TermWidth - len(hint)) / 2
+	for i, r := range hint {
+		screen.SetContent(hx+i, y+2, r, nil, hintStyle)
+	}
 }
 
-func drawEndOverlay(screen tcell.Screen, winner string, youWon bool) {
-	var msg string
-	if youWon {
-		msg = " YOU WIN! "
-	} else {
-		msg = " YOU LOSE! "
+// drawHelpOverlay lists the key bindings over the field.
+func drawHelpOverlay(screen tcell.Screen, keymap *Keymap) {
+	lines := keymap.Lines()
+	width := 0
+	for _, line := range lines {
+		width = max(width, utf8.RuneCountInString(line))
 	}
-	style := tcell.StyleDefault.Foreground(tcell.ColorGreen).Bold(true)
-	if !youWon {
-		style = tcell.StyleDefault.Foreground(tcell.ColorRed).Bold(true)
+	boxed := []string{" KEYS" + strings.Repeat(" ", width-3), ""}
+	for _, line := range lines {
+		boxed = append(boxed, " "+line+strings.Repeat(" ", width-utf8.RuneCountInString(line))+" ")
 	}
+	drawLines(screen, tcell.StyleDefault.Foreground(tcell.ColorWhite).Background(tcell.ColorNavy), boxed...)
+}
+
+func drawCountdownOverlay(screen tcell.Screen, remaining time.Duration) {
+	msg := fmt.Sprintf(" RESUMING IN %d ", int(math.Ceil(remaining.Seconds())))
+	style := tcell.StyleDefault.Foreground(tcell.ColorYellow).Bold(true)
 	x := (TermWidth - len(msg)) / 2
 	y := TermHeight / 2
 	for i, r := range msg {
 		screen.SetContent(x+i, y, r, nil, style)
 	}
-	winnerMsg := fmt.Sprintf("Winner: %s", winner)
-	wx := (TermWidth - len(winnerMsg)) / 2
-	wy := y + 2
-	for i, r := range winnerMsg {
-		screen.SetContent(wx+i, wy, r, nil, style)
-	}
 }
 
-func drawEndPage(screen tcell.Screen, endTime time.Time, reason string) {
-	screen.Clear()
-
-	msg := " " + reason + " "
-	msgStyle := tcell.StyleDefault.Foreground(tcell.ColorRed).Bold(true)
+func drawReconnectingOverlay(screen tcell.Screen, remaining time.Duration) {
+	msg := []rune(" RECONNECTING… ")
+	style := tcell.StyleDefault.Foreground(tcell.ColorYellow).Bold(true)
 	x := (TermWidth - len(msg)) / 2
 	y := TermHeight / 2
 	for i, r := range msg {
-		screen.SetContent(x+i, y, r, nil, msgStyle)
-	}
-
-	subMsg := "Press two key to exit,\n First to create a PollEvent()\n Ans Second to trigger EventKey"
-	subStyle := tcell.StyleDefault.Foreground(tcell.ColorWhite)
-
-	lines := strings.Split(subMsg, "\n")
-
-	startY := y + 2
-
-	for row, line := range lines {
-		sx := (TermWidth - len(line)) / 2
-		sy := startY + row
-
-		for i, r := range line {
-			screen.SetContent(sx+i, sy, r, nil, subStyle)
-		}
+		screen.SetContent(x+i, y, r, nil, style)
 	}
 
-	screen.Show()
-
-	for {
-		switch screen.PollEvent().(type) {
-		case *tcell.EventKey:
-			return
-		default:
-			// small sleep to avoid busy-looping
-			time.Sleep(50 * time.Millisecond)
-		}
+	hint := []rune(fmt.Sprintf(" giving up in %ds ", max(0, int(math.Ceil(remaining.Seconds())))))
+	hintStyle := tcell.StyleDefault.Foreground(tcell.ColorWhite)
+	hx := (TermWidth - len(hint)) / 2
+	for i, r := range hint {
+		screen.SetContent(hx+i, y+2, r, nil, hintStyle)
 	}
 }
 
-func drawWinPage(screen tcell.Screen, endTime time.Time, winstate bool, winner string) {
-	screen.Clear()
-
+func drawEndOverlay(screen tcell.Screen, winner string, youWon bool) {
 	var msg string
-	var msgStyle tcell.Style
-	if winstate {
-		msg = " YOU WON "
-		msgStyle = tcell.StyleDefault.Foreground(tcell.ColorGreen).Bold(true)
+	if youWon {
+		msg = " YOU WIN! "
 	} else {
-		msg = " YOU LOST "
-		msgStyle = tcell.StyleDefault.Foreground(tcell.ColorRed).Bold(true)
+		msg = " YOU LOSE! "
+	}
+	style := tcell.StyleDefault.Foreground(tcell.ColorGreen).Bold(true)
+	if !youWon {
+		style = tcell.StyleDefault.Foreground(tcell.ColorRed).Bold(true)
 	}
 	x := (TermWidth - len(msg)) / 2
 	y := TermHeight / 2
 	for i, r := range msg {
-		screen.SetContent(x+i, y, r, nil, msgStyle)
+		screen.SetContent(x+i, y, r, nil, style)
 	}
-
 	winnerMsg := fmt.Sprintf("Winner: %s", winner)
 	wx := (TermWidth - len(winnerMsg)) / 2
 	wy := y + 2
 	for i, r := range winnerMsg {
-		screen.SetContent(wx+i, wy, r, nil, msgStyle)
+		screen.SetContent(wx+i, wy, r, nil, style)
 	}
+}
 
-	subMsg := "Press two key to exit,\n First to create a PollEvent()\n Ans Second to trigger EventKey"
-	subStyle := tcell.StyleDefault.Foreground(tcell.ColorWhite)
+func drawEndPage(screen tcell.Screen, events <-chan tcell.Event, endTime time.Time, reason string) {
+	style := tcell.StyleDefault.Foreground(tcell.ColorRed).Bold(true)
+	showPage(screen, events, style, " "+reason+" ")
+}
 
-	lines := strings.Split(subMsg, "\n")
+func drawWinPage(screen tcell.Screen, events <-chan tcell.Event, endTime time.Time, winstate bool, winner string) {
+	msg := " YOU LOST "
+	style := tcell.StyleDefault.Foreground(tcell.ColorRed).Bold(true)
+	if winstate {
+		msg = " YOU WON "
+		style = tcell.StyleDefault.Foreground(tcell.ColorGreen).Bold(true)
+	}
+	showPage(screen, events, style, msg, "Winner: "+winner)
+}
 
-	startY := y + 4
+// drawResultPage ends a watched game: the final score of both teams, and
+// the winner, neutrally.
+func drawResultPage(screen tcell.Screen, events <-chan tcell.Event, endTime time.Time, score string, winner string) {
+	style := tcell.StyleDefault.Foreground(tcell.ColorWhite).Bold(true)
+	showPage(screen, events, style, " GAME OVER ", score, "Winner: "+winner)
+}
 
-	for row, line := range lines {
-		sx := (TermWidth - len(line)) / 2
-		sy := startY + row
+// endPageGrace is how long the end pages ignore keys, so that a paddle key
+// still held when the game ends does not skip them.
+const endPageGrace = 300 * time.Millisecond
 
-		for i, r := range line {
-			screen.SetContent(sx+i, sy, r, nil, subStyle)
+// showPage clears the screen, shows lines centered two rows apart with a
+// prompt below them, and waits for a key to come out of events.
+func showPage(screen tcell.Screen, events <-chan tcell.Event, style tcell.Style, lines ...string) {
+	screen.Clear()
+	y := TermHeight / 2
+	promptStyle := tcell.StyleDefault.Foreground(tcell.ColorWhite)
+	for row, line := range append(lines, "Press any key to continue") {
+		lineStyle := style
+		if row == len(lines) {
+			lineStyle = promptStyle
+		}
+		rs := []rune(line)
+		x := (TermWidth - len(rs)) / 2
+		for i, r := range rs {
+			screen.SetContent(x+i, y+2*row, r, nil, lineStyle)
 		}
 	}
-
 	screen.Show()
 
-	for {
-		switch screen.PollEvent().(type) {
-		case *tcell.EventKey:
+	shown := time.Now()
+	for ev := range events {
+		if key, ok := ev.(*tcell.EventKey); ok && key.When().Sub(shown) >= endPageGrace {
 			return
-		default:
-			// small sleep to avoid busy-looping
-			time.Sleep(50 * time.Millisecond)
 		}
 	}
 }
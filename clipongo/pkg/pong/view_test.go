@@ -0,0 +1,39 @@
+package pong_test
+
+import (
+	"testing"
+
+	"clipongo/pkg/api"
+	"clipongo/pkg/pong"
+)
+
+func TestTeamName(t *testing.T) {
+	players := func(names ...string) []api.GamePlayer {
+		var ps []api.GamePlayer
+		for _, name := range names {
+			ps = append(ps, api.GamePlayer{Player: api.Player{Username: name}})
+		}
+		return ps
+	}
+	tests := []struct {
+		name     string
+		players  []api.GamePlayer
+		team     int
+		maxRunes int
+		want     string
+	}{
+		{"1 vs 1 left", players("alice", "bob"), 0, 0, "alice"},
+		{"1 vs 1 right", players("alice", "bob"), 1, 0, "bob"},
+		{"2 vs 2 left", players("alice", "bob", "carol", "dave"), 0, 0, "alice & carol"},
+		{"2 vs 2 right", players("alice", "bob", "carol", "dave"), 1, 0, "bob & dave"},
+		{"truncated", players("alexandrina", "bob", "carol", "dave"), 0, 4, "alex & caro"},
+		{"not truncated", players("alexandrina", "bob"), 0, 0, "alexandrina"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pong.TeamName(tt.players, tt.team, tt.maxRunes); got != tt.want {
+				t.Errorf("TeamName = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package realtime
+
+import (
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Heartbeat describes the ping/pong exchange that measures the round trip
+// time and detects a dead connection before TCP does.
+type Heartbeat struct {
+	// Interval between pings. Zero or less disables heartbeats.
+	Interval time.Duration
+	// Timeout is how long the connection may stay silent after a missed
+	// pong before it is considered lost and reconnected.
+	Timeout time.Duration
+}
+
+// DefaultHeartbeat pings every second and gives up on a connection silent
+// for 4 seconds.
+func DefaultHeartbeat() Heartbeat {
+	return Heartbeat{Interval: time.Second, Timeout: 3 * time.Second}
+}
+
+// WithHeartbeat replaces DefaultHeartbeat.
+func WithHeartbeat(h Heartbeat) Option {
+	return func(s *Session) {
+		s.heartbeat = h
+	}
+}
+
+// readDeadline is how long to wait for the next frame or pong.
+func (h Heartbeat) readDeadline() time.Duration {
+	return h.Interval + h.Timeout
+}
+
+// Stats describes the quality of the connection.
+type Stats struct {
+	// RTT is the smoothed round trip time of pings, zero until the first
+	// pong.
+	RTT time.Duration
+	// Jitter is the mean deviation between consecutive round trips.
+	Jitter time.Duration
+	// FPS is the number of messages received per second, measured over
+	// about a second.
+	FPS float64
+	// Received counts the messages received since Connect.
+	Received uint64
+}
+
+// stats accumulates Stats from the reader goroutine.
+type stats struct {
+	mu          sync.Mutex
+	s           Stats
+	lastRTT     time.Duration
+	windowStart time.Time
+	windowCount int
+}
+
+// fpsWindow is the period over which FPS is measured.
+const fpsWindow = time.Second
+
+func (st *stats) addRTT(rtt time.Duration) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	// Smooth like TCP does (RFC 6298) and estimate the jitter like RTP
+	// does (RFC 3550).
+	if st.s.RTT == 0 {
+		st.s.RTT = rtt
+	} else {
+		st.s.RTT += (rtt - st.s.RTT) / 8
+		d := rtt - st.lastRTT
+		if d < 0 {
+			d = -d
+		}
+		st.s.Jitter += (d - st.s.Jitter) / 16
+	}
+	st.lastRTT = rtt
+}
+
+func (st *stats) addMessage(now time.Time) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.s.Received++
+	st.windowCount++
+	if st.windowStart.IsZero() {
+		st.windowStart = now
+	}
+	if elapsed := now.Sub(st.windowStart); elapsed >= fpsWindow {
+		st.s.FPS = float64(st.windowCount) / elapsed.Seconds()
+		st.windowStart = now
+		st.windowCount = 0
+	}
+}
+
+func (st *stats) get(now time.Time) Stats {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	s := st.s
+	// Nothing came for a while: do not show the rate of the last window.
+	if elapsed := now.Sub(st.windowStart); !st.windowStart.IsZero() && elapsed >= 2*fpsWindow {
+		s.FPS = float64(st.windowCount) / elapsed.Seconds()
+	}
+	return s
+}
+
+// Stats returns the current connection quality.
+func (s *Session) Stats() Stats {
+	return s.stats.get(time.Now())
+}
+
+// ping sends a ping carrying its send time every interval until stop is
+// closed. The pong handler set by read measures the round trip from it.
+func (s *Session) ping(conn *websocket.Conn, stop <-chan struct{}) {
+	t := time.NewTicker(s.heartbeat.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-t.C:
+			data := []byte(strconv.FormatInt(now.UnixNano(), 10))
+			if err := conn.WriteControl(websocket.PingMessage, data, now.Add(s.heartbeat.Timeout)); err != nil {
+				log.Printf("WebSocket ping error: %v", err)
+				return
+			}
+		}
+	}
+}
+
+// startHeartbeat sets the read deadline and pong handler of conn and
+// starts pinging it. Call the returned function when conn is done.
+func (s *Session) startHeartbeat(conn *websocket.Conn) (stop func()) {
+	if s.heartbeat.Interval <= 0 {
+		return func() {}
+	}
+	conn.SetReadDeadline(time.Now().Add(s.heartbeat.readDeadline()))
+	conn.SetPongHandler(func(appData string) error {
+		now := time.Now()
+		if sent, err := strconv.ParseInt(appData, 10, 64); err == nil {
+			s.stats.addRTT(now.Sub(time.Unix(0, sent)))
+		}
+		return conn.SetReadDeadline(now.Add(s.heartbeat.readDeadline()))
+	})
+	done := make(chan struct{})
+	go s.ping(conn, done)
+	return func() { close(done) }
+}
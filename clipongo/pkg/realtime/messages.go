@@ -0,0 +1,45 @@
+package realtime
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Message types of the SocketData union shared with the backend.
+const (
+	TypeGameState  = "game_state"
+	TypePaddleMove = "paddle_move"
+)
+
+// Message is the {type, payload} envelope of every WebSocket message.
+type Message struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// NewMessage encodes payload into a Message of type typ.
+func NewMessage(typ string, payload any) (Message, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Message{}, fmt.Errorf("failed to encode %s payload: %w", typ, err)
+	}
+	return Message{Type: typ, Payload: data}, nil
+}
+
+// Direction is the direction of a paddle move.
+type Direction string
+
+const (
+	Up   Direction = "up"
+	Down Direction = "down"
+)
+
+// PaddleMove is the payload of a paddle_move message, sent by players to
+// start or stop moving their paddle. The payload of game_state is an
+// api.GameState.
+type PaddleMove struct {
+	// Paddle is the index of the player in GameState.Players.
+	Paddle    int       `json:"paddle"`
+	Direction Direction `json:"direction"`
+	Moving    bool      `json:"moving"`
+}
@@ -0,0 +1,150 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"clipongo/pkg/api"
+
+	"github.com/gorilla/websocket"
+)
+
+// ReconnectPolicy describes how a Session reestablishes a lost connection.
+type ReconnectPolicy struct {
+	// BaseDelay is the wait before the second attempt, doubled for each
+	// following one up to MaxDelay. The first attempt is immediate.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Timeout is how long to keep trying before giving up. Zero or less
+	// disables reconnection.
+	Timeout time.Duration
+}
+
+// DefaultReconnectPolicy keeps trying for 30 seconds.
+func DefaultReconnectPolicy() ReconnectPolicy {
+	return ReconnectPolicy{
+		BaseDelay: 250 * time.Millisecond,
+		MaxDelay:  4 * time.Second,
+		Timeout:   30 * time.Second,
+	}
+}
+
+// delay returns the wait after failed attempt n, starting at 1. Its upper
+// half is random so that the players of a game do not retry in lockstep.
+func (p ReconnectPolicy) delay(n int) time.Duration {
+	d := p.BaseDelay << (n - 1)
+	if d > p.MaxDelay || d <= 0 {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// ConnState is the state of the connection of a Session.
+type ConnState int
+
+const (
+	Connected ConnState = iota
+	Reconnecting
+)
+
+// Status is reported to the OnStatus callback.
+type Status struct {
+	State ConnState
+	// Attempt counts the reconnection attempts, from 1.
+	Attempt int
+	// Err is why the connection was lost, or why the last attempt failed.
+	Err error
+	// GiveUpAt is when reconnecting stops.
+	GiveUpAt time.Time
+}
+
+// reconnectable reports whether a connection that failed with err is
+// worth reestablishing. The backend ends games, and rejects those who are
+// not players, by closing the socket on purpose.
+func reconnectable(err error) bool {
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		switch closeErr.Code {
+		case websocket.CloseNormalClosure, websocket.CloseNoStatusReceived, websocket.ClosePolicyViolation:
+			return false
+		}
+	}
+	return true
+}
+
+// reconnect retries with backoff until a new connection is up, the policy
+// timeout expires, the game no longer exists or the session is closed.
+func (s *Session) reconnect(cause error) (*websocket.Conn, error) {
+	s.setConn(nil)
+	giveUpAt := time.Now().Add(s.policy.Timeout)
+	ctx, cancel := context.WithDeadline(context.Background(), giveUpAt)
+	defer cancel()
+	go func() {
+		select {
+		case <-s.closing:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for attempt := 1; ; attempt++ {
+		s.notify(Status{State: Reconnecting, Attempt: attempt, Err: cause, GiveUpAt: giveUpAt})
+		conn, err := s.resync(ctx)
+		if err == nil {
+			if !s.setConn(conn) {
+				return nil, ErrClosed
+			}
+			log.Printf("Reconnected to game %s after %d attempt(s)", s.gameID, attempt)
+			s.notify(Status{State: Connected, Attempt: attempt})
+			return conn, nil
+		}
+		log.Printf("Reconnection attempt %d to game %s failed: %v", attempt, s.gameID, err)
+		if errors.Is(err, api.ErrNotFound) {
+			return nil, fmt.Errorf("game %s is over: %w", s.gameID, err)
+		}
+		cause = err
+
+		t := time.NewTimer(s.policy.delay(attempt))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, fmt.Errorf("gave up reconnecting to game %s after %s: %w", s.gameID, s.policy.Timeout, cause)
+		case <-t.C:
+		}
+	}
+}
+
+// resync fetches the game, which renews the token if it expired, hands its
+// state to the game_state handler and connects again. The server then
+// sends its own frames as usual.
+func (s *Session) resync(ctx context.Context) (*websocket.Conn, error) {
+	state, err := s.client.GetGameState(ctx, s.gameID)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if payload, err := json.Marshal(state); err == nil {
+		s.handle(Message{Type: TypeGameState, Payload: payload})
+	}
+	return conn, nil
+}
+
+func (s *Session) notify(st Status) {
+	s.mu.RLock()
+	fn := s.onStatus
+	s.mu.RUnlock()
+	if fn != nil {
+		fn(st)
+	}
+}
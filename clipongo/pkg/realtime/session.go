@@ -0,0 +1,356 @@
+// Package realtime speaks the WebSocket protocol of a game: it dispatches
+// the messages the server sends to the handlers registered for their type
+// and serializes the messages sent to the server on a single writer.
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"clipongo/pkg/api"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrClosed is returned by Send once the session is over.
+var ErrClosed = errors.New("realtime session closed")
+
+// Handler handles the payload of a message of the type it was registered
+// for. Handlers run on the reader goroutine, one at a time.
+type Handler func(payload json.RawMessage) error
+
+// sendQueue is how many messages may wait for the writer.
+const sendQueue = 32
+
+// closeTimeout bounds the wait for the close frame to be written.
+const closeTimeout = time.Second
+
+// Session is the WebSocket connection of a player to a game. A lost
+// connection is reestablished according to its ReconnectPolicy.
+type Session struct {
+	client    *api.Client
+	gameID    string
+	policy    ReconnectPolicy
+	heartbeat Heartbeat
+	stats     stats
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	onStatus func(Status)
+
+	connMu    sync.Mutex
+	conn      *websocket.Conn // nil while reconnecting
+	connected bool
+
+	out       chan Message
+	closing   chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+	err       error // set before done is closed
+}
+
+// Option configures a Session.
+type Option func(*Session)
+
+// WithReconnect replaces DefaultReconnectPolicy.
+func WithReconnect(p ReconnectPolicy) Option {
+	return func(s *Session) {
+		s.policy = p
+	}
+}
+
+// NewSession returns a session for game gameID. Register handlers before
+// calling Connect so that no message is missed.
+func NewSession(client *api.Client, gameID string, opts ...Option) *Session {
+	s := &Session{
+		client:    client,
+		gameID:    gameID,
+		policy:    DefaultReconnectPolicy(),
+		heartbeat: DefaultHeartbeat(),
+		handlers:  make(map[string]Handler),
+		out:       make(chan Message, sendQueue),
+		closing:   make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Handle registers h for the messages of type typ, replacing any previous
+// handler. Messages without a handler are logged and dropped.
+func (s *Session) Handle(typ string, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[typ] = h
+}
+
+// OnGameState registers fn for game_state messages.
+func (s *Session) OnGameState(fn func(*api.GameState)) {
+	s.Handle(TypeGameState, func(payload json.RawMessage) error {
+		var state api.GameState
+		if err := json.Unmarshal(payload, &state); err != nil {
+			return err
+		}
+		fn(&state)
+		return nil
+	})
+}
+
+// OnPaddleMove registers fn for paddle_move messages.
+func (s *Session) OnPaddleMove(fn func(PaddleMove)) {
+	s.Handle(TypePaddleMove, func(payload json.RawMessage) error {
+		var move PaddleMove
+		if err := json.Unmarshal(payload, &move); err != nil {
+			return err
+		}
+		fn(move)
+		return nil
+	})
+}
+
+// OnStatus registers fn to be told when the connection is lost and when it
+// is reestablished. fn runs on the reader goroutine.
+func (s *Session) OnStatus(fn func(Status)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onStatus = fn
+}
+
+// Connect opens the WebSocket and starts reading and writing messages.
+// Only this first connection is not retried.
+func (s *Session) Connect(ctx context.Context) error {
+	if s.connected {
+		return errors.New("realtime session already connected")
+	}
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	s.connected = true
+	s.setConn(conn)
+
+	go s.write()
+	go s.run(conn)
+	return nil
+}
+
+func (s *Session) dial(ctx context.Context) (*websocket.Conn, error) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+s.client.GetToken())
+	header.Set("Origin", s.client.Origin())
+
+	conn, resp, err := s.client.Dialer().DialContext(ctx, s.client.RealtimeURL(s.gameID), header)
+	if err != nil {
+		log.Printf("WebSocket dial error: %v", err)
+		if resp != nil {
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			log.Printf("Response status: %s, body: %s", resp.Status, string(body))
+		}
+		return nil, fmt.Errorf("failed to connect to game %s: %w", s.gameID, err)
+	}
+	return conn, nil
+}
+
+// setConn makes conn the connection used by the writer, unless the
+// session was closed meanwhile.
+func (s *Session) setConn(conn *websocket.Conn) bool {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	select {
+	case <-s.closing:
+		if conn != nil {
+			conn.Close()
+		}
+		return false
+	default:
+	}
+	s.conn = conn
+	return true
+}
+
+func (s *Session) currentConn() *websocket.Conn {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	return s.conn
+}
+
+// Send queues a message of type typ for the writer. It is safe to call from
+// any goroutine, and before Connect.
+func (s *Session) Send(typ string, payload any) error {
+	msg, err := NewMessage(typ, payload)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-s.done:
+		return ErrClosed
+	default:
+	}
+	select {
+	case s.out <- msg:
+		return nil
+	case <-s.done:
+		return ErrClosed
+	}
+}
+
+// SendPaddleMove sends a paddle_move message.
+func (s *Session) SendPaddleMove(move PaddleMove) error {
+	return s.Send(TypePaddleMove, move)
+}
+
+// Done is closed when the session is over, either closed by Close or
+// lost for good.
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns why the session was lost once Done is closed, or nil if
+// Close ended it.
+func (s *Session) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Close ends the session with a normal closure, also stopping any
+// reconnection. It must not be called concurrently with Connect.
+func (s *Session) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+		if !s.connected {
+			close(s.done)
+			return
+		}
+		if conn := s.currentConn(); conn != nil {
+			msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+			conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(closeTimeout))
+			conn.Close()
+		}
+	})
+	<-s.done
+	return nil
+}
+
+func (s *Session) isClosing() bool {
+	select {
+	case <-s.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+// run reads conn, and the connections replacing it, until the session is
+// closed or cannot reconnect.
+func (s *Session) run(conn *websocket.Conn) {
+	defer func() {
+		log.Printf("WebSocket reader exiting")
+		close(s.done)
+	}()
+	for {
+		err := s.read(conn)
+		if s.isClosing() {
+			return
+		}
+		if s.policy.Timeout <= 0 || !reconnectable(err) {
+			s.err = err
+			return
+		}
+		if conn, err = s.reconnect(err); err != nil {
+			if !s.isClosing() {
+				s.err = err
+			}
+			return
+		}
+	}
+}
+
+// read dispatches the messages of conn until it fails, or stays silent
+// past the heartbeat deadline.
+func (s *Session) read(conn *websocket.Conn) error {
+	stop := s.startHeartbeat(conn)
+	defer stop()
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if s.isClosing() {
+				return err
+			}
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("WebSocket closed normally")
+			} else {
+				log.Printf("WebSocket read error: %v", err)
+			}
+			return err
+		}
+		now := time.Now()
+		s.stats.addMessage(now)
+		if s.heartbeat.Interval > 0 {
+			conn.SetReadDeadline(now.Add(s.heartbeat.readDeadline()))
+		}
+		s.dispatch(data)
+	}
+}
+
+func (s *Session) dispatch(data []byte) {
+	if len(data) == 0 {
+		log.Printf("Received empty message: ignoring")
+		return
+	}
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		log.Printf("Failed to parse message: %v", err)
+		return
+	}
+	s.handle(msg)
+}
+
+func (s *Session) handle(msg Message) {
+	s.mu.RLock()
+	h, ok := s.handlers[msg.Type]
+	s.mu.RUnlock()
+	if !ok {
+		log.Printf("Ignoring %q message: no handler", msg.Type)
+		return
+	}
+	if err := h(msg.Payload); err != nil {
+		log.Printf("Failed to handle %q message: %v", msg.Type, err)
+	}
+}
+
+// write is the only goroutine writing messages, as websocket.Conn
+// supports a single concurrent writer. Messages sent while reconnecting
+// are dropped: they would be stale by the time the game resumes.
+func (s *Session) write() {
+	for {
+		select {
+		case msg := <-s.out:
+			conn := s.currentConn()
+			if conn == nil {
+				log.Printf("Dropping %q message: reconnecting", msg.Type)
+				continue
+			}
+			if err := conn.WriteJSON(msg); err != nil {
+				log.Printf("WebSocket send error: %v", err)
+				// Let the reader notice and reconnect.
+				conn.Close()
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
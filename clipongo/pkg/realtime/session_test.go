@@ -0,0 +1,257 @@
+package realtime_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"clipongo/pkg/api"
+	"clipongo/pkg/apitest"
+	"clipongo/pkg/realtime"
+)
+
+const waitTimeout = 3 * time.Second
+
+// newSession returns an unconnected session of alice in a game against bob.
+func newSession(t *testing.T) (*apitest.Server, *realtime.Session, string) {
+	t.Helper()
+	srv := apitest.NewServer()
+	t.Cleanup(srv.Close)
+	client, err := api.NewClient(srv.URL, srv.Login("alice"), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := srv.CreateGame("alice", "bob")
+	return srv, realtime.NewSession(client, state.ID), state.ID
+}
+
+func TestSessionDispatchesByType(t *testing.T) {
+	srv, session, id := newSession(t)
+	states := make(chan *api.GameState, 1)
+	session.OnGameState(func(state *api.GameState) { states <- state })
+	chats := make(chan string, 1)
+	session.Handle("chat", func(payload json.RawMessage) error {
+		var text string
+		if err := json.Unmarshal(payload, &text); err != nil {
+			return err
+		}
+		chats <- text
+		return nil
+	})
+	if err := session.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case state := <-states:
+		if state.ID != id || len(state.Players) != 2 {
+			t.Errorf("initial state = %+v", state)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatal("no initial game_state")
+	}
+
+	for _, typ := range []string{"unknown", "chat"} {
+		msg, err := realtime.NewMessage(typ, "gg")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := srv.Send(id, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case text := <-chats:
+		if text != "gg" {
+			t.Errorf("chat = %q, want %q", text, "gg")
+		}
+	case <-time.After(waitTimeout):
+		t.Fatal("chat handler not called")
+	}
+
+	if err := session.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	if err := session.Err(); err != nil {
+		t.Errorf("Err after Close = %v, want nil", err)
+	}
+}
+
+func TestSessionSerializesSends(t *testing.T) {
+	srv, session, id := newSession(t)
+	if err := session.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	const senders, moves = 8, 25
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < moves; j++ {
+				move := realtime.PaddleMove{Paddle: 0, Direction: realtime.Up, Moving: j%2 == 0}
+				if err := session.SendPaddleMove(move); err != nil {
+					t.Errorf("SendPaddleMove: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(waitTimeout)
+	for len(srv.Moves(id)) < senders*moves {
+		if time.Now().After(deadline) {
+			t.Fatalf("server got %d moves, want %d", len(srv.Moves(id)), senders*moves)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, m := range srv.Moves(id) {
+		if m.Paddle != 0 || m.Direction != realtime.Up {
+			t.Fatalf("unexpected move %+v", m)
+		}
+	}
+}
+
+func TestSessionReconnects(t *testing.T) {
+	srv, session, id := newSession(t)
+	states := make(chan *api.GameState, 4)
+	session.OnGameState(func(state *api.GameState) { states <- state })
+	statuses := make(chan realtime.Status, 4)
+	session.OnStatus(func(st realtime.Status) { statuses <- st })
+	if err := session.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	<-states
+
+	// The token expired while offline: resyncing must log in again.
+	srv.RevokeTokens()
+	srv.Disconnect(id, "alice")
+
+	for _, want := range []realtime.ConnState{realtime.Reconnecting, realtime.Connected} {
+		select {
+		case st := <-statuses:
+			if st.State != want {
+				t.Fatalf("status = %+v, want state %v", st, want)
+			}
+		case <-time.After(waitTimeout):
+			t.Fatalf("no status %v", want)
+		}
+	}
+	select {
+	case state := <-states:
+		if state.ID != id {
+			t.Errorf("resynced state = %+v", state)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatal("no state after reconnecting")
+	}
+
+	if err := session.SendPaddleMove(realtime.PaddleMove{Direction: realtime.Down, Moving: true}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(waitTimeout)
+	for len(srv.Moves(id)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("move not received after reconnecting")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSessionDetectsStaleConnection(t *testing.T) {
+	srv := apitest.NewServer()
+	t.Cleanup(srv.Close)
+	client, err := api.NewClient(srv.URL, srv.Login("alice"), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := srv.CreateGame("alice", "bob").ID
+	heartbeat := realtime.Heartbeat{Interval: 20 * time.Millisecond, Timeout: 60 * time.Millisecond}
+	session := realtime.NewSession(client, id, realtime.WithHeartbeat(heartbeat))
+	statuses := make(chan realtime.Status, 4)
+	session.OnStatus(func(st realtime.Status) { statuses <- st })
+	if err := session.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	deadline := time.Now().Add(waitTimeout)
+	for session.Stats().RTT == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no round trip measured")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if st := session.Stats(); st.Received == 0 || st.RTT > time.Second {
+		t.Errorf("Stats = %+v", st)
+	}
+
+	srv.Mute(id, "alice")
+	for _, want := range []realtime.ConnState{realtime.Reconnecting, realtime.Connected} {
+		select {
+		case st := <-statuses:
+			if st.State != want {
+				t.Fatalf("status = %+v, want state %v", st, want)
+			}
+		case <-time.After(waitTimeout):
+			t.Fatalf("no status %v", want)
+		}
+	}
+}
+
+func TestSessionGivesUpReconnecting(t *testing.T) {
+	srv := apitest.NewServer()
+	t.Cleanup(srv.Close)
+	client, err := api.NewClient(srv.URL, srv.Login("alice"), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := srv.CreateGame("alice", "bob").ID
+	policy := realtime.ReconnectPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond, Timeout: 300 * time.Millisecond}
+	session := realtime.NewSession(client, id, realtime.WithReconnect(policy))
+	if err := session.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.Fail("GET", "/api/game/"+id, 503, 1000)
+	srv.Disconnect(id, "alice")
+
+	select {
+	case <-session.Done():
+	case <-time.After(waitTimeout):
+		t.Fatal("session still reconnecting after its timeout")
+	}
+	var apiErr *api.APIError
+	if err := session.Err(); !errors.As(err, &apiErr) || apiErr.StatusCode != 503 {
+		t.Errorf("Err = %v, want the last failure", err)
+	}
+}
+
+// A game that ended while offline is not waited for.
+func TestSessionLost(t *testing.T) {
+	srv, session, id := newSession(t)
+	if err := session.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	srv.EndGame(id)
+
+	select {
+	case <-session.Done():
+	case <-time.After(waitTimeout):
+		t.Fatal("session not done after the server closed it")
+	}
+	if err := session.Err(); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("Err = %v, want ErrNotFound", err)
+	}
+	err := session.SendPaddleMove(realtime.PaddleMove{Direction: realtime.Down})
+	if !errors.Is(err, realtime.ErrClosed) {
+		t.Errorf("SendPaddleMove after loss = %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,153 @@
+// Package session persists login tokens between runs of the CLI.
+package session
+
+import (
+	"clipongo/pkg/config"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrNoSession is returned by TokenStore.Load when nothing is saved for the
+// server.
+var ErrNoSession = errors.New("no saved session")
+
+type Session struct {
+	Server   string    `json:"server"`
+	Username string    `json:"username"`
+	Token    string    `json:"token"`
+	SavedAt  time.Time `json:"saved_at"`
+}
+
+// TokenStore keeps one token per server and username. FileStore is the
+// default implementation; an OS keyring can be plugged in instead.
+type TokenStore interface {
+	// Load returns the most recently saved session for server.
+	Load(server string) (*Session, error)
+	// Save stores s, replacing any session of the same server and user.
+	Save(s Session) error
+	// Delete forgets the session of username on server.
+	Delete(server, username string) error
+}
+
+// DefaultPath returns sessions.json in the config dir.
+func DefaultPath() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "sessions.json"), nil
+}
+
+// FileStore stores sessions in a JSON file only readable by the user.
+type FileStore struct {
+	path string
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+type sessionFile struct {
+	Sessions []Session `json:"sessions"`
+}
+
+func (f *FileStore) Load(server string) (*Session, error) {
+	file, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	var latest *Session
+	for i, s := range file.Sessions {
+		if s.Server == server && (latest == nil || s.SavedAt.After(latest.SavedAt)) {
+			latest = &file.Sessions[i]
+		}
+	}
+	if latest == nil {
+		return nil, ErrNoSession
+	}
+	return latest, nil
+}
+
+func (f *FileStore) Save(s Session) error {
+	file, err := f.read()
+	if err != nil {
+		return err
+	}
+	if s.SavedAt.IsZero() {
+		s.SavedAt = time.Now()
+	}
+	file.Sessions = append(without(file.Sessions, s.Server, s.Username), s)
+	return f.write(file)
+}
+
+func (f *FileStore) Delete(server, username string) error {
+	file, err := f.read()
+	if err != nil {
+		return err
+	}
+	file.Sessions = without(file.Sessions, server, username)
+	return f.write(file)
+}
+
+func without(sessions []Session, server, username string) []Session {
+	kept := sessions[:0]
+	for _, s := range sessions {
+		if s.Server != server || s.Username != username {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+func (f *FileStore) read() (*sessionFile, error) {
+	var file sessionFile
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &file, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sessions: %w", err)
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("corrupt sessions file %s: %w", f.path, err)
+	}
+	return &file, nil
+}
+
+// write replaces the file atomically so that a crash never leaves a
+// truncated file behind, and always with mode 0600.
+func (f *FileStore) write(file *sessionFile) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode sessions: %w", err)
+	}
+	dir := filepath.Dir(f.path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create sessions dir: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".sessions-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to write sessions: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write sessions: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write sessions: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write sessions: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("failed to write sessions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package session
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	alice := Session{Server: "https://a", Username: "alice", Token: "ta", SavedAt: t0}
+	bob := Session{Server: "https://a", Username: "bob", Token: "tb", SavedAt: t0.Add(time.Minute)}
+	other := Session{Server: "https://b", Username: "alice", Token: "tc", SavedAt: t0.Add(2 * time.Minute)}
+	aliceAgain := Session{Server: "https://a", Username: "alice", Token: "td", SavedAt: t0.Add(3 * time.Minute)}
+
+	tests := []struct {
+		name   string
+		save   []Session
+		delete [][2]string // server, username
+		server string
+		want   string // token, empty for ErrNoSession
+	}{
+		{"empty", nil, nil, "https://a", ""},
+		{"saved", []Session{alice}, nil, "https://a", "ta"},
+		{"latest of the server", []Session{alice, bob, other}, nil, "https://a", "tb"},
+		{"other server", []Session{alice, bob, other}, nil, "https://b", "tc"},
+		{"replaced", []Session{alice, bob, aliceAgain}, nil, "https://a", "td"},
+		{"deleted", []Session{alice, bob}, [][2]string{{"https://a", "bob"}}, "https://a", "ta"},
+		{"all deleted", []Session{alice}, [][2]string{{"https://a", "alice"}}, "https://a", ""},
+		{"delete unknown", []Session{alice}, [][2]string{{"https://b", "alice"}}, "https://a", "ta"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewFileStore(filepath.Join(t.TempDir(), "sessions.json"))
+			for _, s := range tt.save {
+				if err := store.Save(s); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, d := range tt.delete {
+				if err := store.Delete(d[0], d[1]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := store.Load(tt.server)
+			switch {
+			case tt.want == "":
+				if !errors.Is(err, ErrNoSession) {
+					t.Errorf("Load(%q) = %+v, %v, want ErrNoSession", tt.server, got, err)
+				}
+			case err != nil:
+				t.Errorf("Load(%q): %v", tt.server, err)
+			case got.Token != tt.want:
+				t.Errorf("Load(%q) token = %q, want %q", tt.server, got.Token, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileStoreWrite(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "clipongo")
+	path := filepath.Join(dir, "sessions.json")
+	store := NewFileStore(path)
+	if err := store.Save(Session{Server: "https://a", Username: "alice", Token: "ta"}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("sessions file mode = %v, want 0600", mode)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("files left in %s: %v, want only sessions.json", dir, entries)
+	}
+	s, err := store.Load("https://a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.SavedAt.IsZero() {
+		t.Error("SavedAt not set on save")
+	}
+}
+
+func TestFileStoreCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store := NewFileStore(path)
+	if _, err := store.Load("https://a"); err == nil || errors.Is(err, ErrNoSession) {
+		t.Errorf("Load of a corrupt file = %v, want a decoding error", err)
+	}
+	if err := store.Save(Session{Server: "https://a", Username: "alice"}); err == nil {
+		t.Error("Save over a corrupt file succeeded, losing its content")
+	}
+}